@@ -0,0 +1,58 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	cfg "sigs.k8s.io/controller-runtime/pkg/config/v1alpha1"
+)
+
+//+kubebuilder:object:root=true
+
+// OperatorConfig is the configuration file of the operator, passed with --config.
+// The flags of the operator override the values of the file.
+type OperatorConfig struct {
+	metav1.TypeMeta `json:",inline"`
+
+	// ControllerManagerConfigurationSpec configures the manager, like the metrics and probe addresses and the leader election
+	cfg.ControllerManagerConfigurationSpec `json:",inline"`
+
+	// Namespaces are the namespaces to watch, all namespaces if empty
+	Namespaces []string `json:"namespaces,omitempty"`
+
+	// Generator configures the generation of the routing-data
+	Generator GeneratorConfig `json:"generator,omitempty"`
+
+	// RouterImage is the image of the kamailio container of the router pods
+	RouterImage string `json:"routerImage,omitempty"`
+
+	// ControllerImage is the image of the kasico controller sidecar of the router pods
+	ControllerImage string `json:"controllerImage,omitempty"`
+}
+
+// GeneratorConfig configures the generation of the routing-data
+type GeneratorConfig struct {
+	// DebounceTime is the time without further changes, after which the routing-data is generated
+	DebounceTime *metav1.Duration `json:"debounceTime,omitempty"`
+
+	// MaxWait is the longest time a change is delayed, if changes keep coming in. Unlimited if not set.
+	MaxWait *metav1.Duration `json:"maxWait,omitempty"`
+}
+
+func init() {
+	SchemeBuilder.Register(&OperatorConfig{})
+}
@@ -50,6 +50,15 @@ type IngressBackend struct {
 
 type IngressBackendService struct {
 	Name string `json:"name,omitempty"`
+
+	// Port of the Service the endpoints are resolved for. Defaults to the first port of the Service.
+	Port IngressBackendServicePort `json:"port,omitempty"`
+}
+
+// IngressBackendServicePort references a port of the Service by name or number
+type IngressBackendServicePort struct {
+	Name   string `json:"name,omitempty"`
+	Number int32  `json:"number,omitempty"`
 }
 
 // IngressStatus defines the observed state of Ingress
@@ -59,6 +68,26 @@ type IngressStatus struct {
 
 	// Conditions represent the latest available observations of an object's state
 	Conditions []metav1.Condition `json:"conditions"`
+
+	// Preview is set for Ingresses with the "kasico.dry-run" annotation, and lists
+	// the rules the Ingress would change on each RouterInstance.
+	Preview []IngressPreview `json:"preview,omitempty"`
+}
+
+// IngressPreview lists the changes of the routing-data of a RouterInstance, if the
+// Ingress would be applied.
+type IngressPreview struct {
+	// RouterInstance is the namespace/name of the RouterInstance
+	RouterInstance string `json:"routerInstance"`
+
+	// Added are the rules which would be added
+	Added []string `json:"added,omitempty"`
+
+	// Removed are the rules which would be removed
+	Removed []string `json:"removed,omitempty"`
+
+	// Shadowed are the conflicting rules, with the rule shadowing them
+	Shadowed []string `json:"shadowed,omitempty"`
 }
 
 //+kubebuilder:object:root=true
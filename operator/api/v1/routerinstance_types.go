@@ -34,8 +34,29 @@ type RouterInstanceSpec struct {
 	// TemplateConfigMapName is the name of the configMap for the kamailio config files.
 	TemplateConfigMapName string `json:"templateConfigMapName,omitempty"`
 
+	// TemplateConfigMaps are layered in order, keys of later ConfigMaps override earlier ones,
+	// e.g. the base templates of the platform team, followed by overrides of this instance.
+	// TemplateConfigMapName is added as the last layer, if set.
+	TemplateConfigMaps []string `json:"templateConfigMaps,omitempty"`
+
+	// TemplateSecrets are the names of Secrets, whose keys are available in the templates as .Secrets.
+	// They are layered in order like the TemplateConfigMaps.
+	TemplateSecrets []string `json:"templateSecrets,omitempty"`
+
 	// RouterService defines configuration values for the generated service
 	RouterService RouterServiceSpec `json:"routerService,omitempty"`
+
+	// ResolveEndpoints adds the ready endpoints of the backend services to the routing-data.
+	// Once a RouterInstance sets it, the operator watches the EndpointSlices of the whole cluster.
+	ResolveEndpoints bool `json:"resolveEndpoints,omitempty"`
+
+	// RevisionHistoryLimit is the number of routing-data revisions to keep
+	//+kubebuilder:default=10
+	RevisionHistoryLimit int `json:"revisionHistoryLimit,omitempty"`
+
+	// PinnedRevision is the name of a routing-data revision ConfigMap, which is published
+	// instead of the current routing-data. This is used to roll back a bad change.
+	PinnedRevision string `json:"pinnedRevision,omitempty"`
 }
 
 // RouterServiceSpec defines configuration values for the generated service
@@ -53,6 +74,16 @@ type RouterServiceSpec struct {
 	AdvertiseAddress string `json:"advertiseAddress,omitempty"`
 }
 
+// TemplateSources returns the names of all template ConfigMaps, in the order they are layered
+func (spec *RouterInstanceSpec) TemplateSources() []string {
+	sources := append([]string{}, spec.TemplateConfigMaps...)
+	if spec.TemplateConfigMapName != "" {
+		sources = append(sources, spec.TemplateConfigMapName)
+	}
+
+	return sources
+}
+
 // RouterInstanceStatus defines the observed state of RouterInstance
 type RouterInstanceStatus struct {
 	// INSERT ADDITIONAL STATUS FIELD - define observed state of cluster
@@ -69,6 +100,35 @@ type RouterInstanceStatus struct {
 
 	// RouterDataHash is used for change-tracking
 	RouterDataHash string `json:"routerDataHash,omitempty"`
+
+	// RoutingDataGeneration is incremented every time the RouterDataHash changes
+	RoutingDataGeneration int `json:"routingDataGeneration,omitempty"`
+
+	// CurrentRevision is the name of the published routing-data revision ConfigMap
+	CurrentRevision string `json:"currentRevision,omitempty"`
+
+	// ReadyPods is the number of ready router pods
+	ReadyPods int `json:"readyPods,omitempty"`
+
+	// UpdatedPods is the number of router pods, which applied the published routing-data
+	UpdatedPods int `json:"updatedPods,omitempty"`
+
+	// AppliedRoutingData counts the router pods by the routing-data they applied
+	AppliedRoutingData []AppliedRoutingData `json:"appliedRoutingData,omitempty"`
+}
+
+// AppliedRoutingData is a routing-data applied by router pods
+type AppliedRoutingData struct {
+	// Hash of the routing-data, like RouterDataHash
+	Hash string `json:"hash"`
+
+	Generation int `json:"generation,omitempty"`
+
+	// Pods is the number of router pods, which applied this routing-data
+	Pods int `json:"pods"`
+
+	// ReadyPods is the number of these pods, which are ready
+	ReadyPods int `json:"readyPods"`
 }
 
 //+kubebuilder:object:root=true
@@ -26,6 +26,21 @@ import (
 	runtime "k8s.io/apimachinery/pkg/runtime"
 )
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AppliedRoutingData) DeepCopyInto(out *AppliedRoutingData) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AppliedRoutingData.
+func (in *AppliedRoutingData) DeepCopy() *AppliedRoutingData {
+	if in == nil {
+		return nil
+	}
+	out := new(AppliedRoutingData)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *Ingress) DeepCopyInto(out *Ingress) {
 	*out = *in
@@ -72,6 +87,7 @@ func (in *IngressBackend) DeepCopy() *IngressBackend {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *IngressBackendService) DeepCopyInto(out *IngressBackendService) {
 	*out = *in
+	out.Port = in.Port
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new IngressBackendService.
@@ -84,6 +100,21 @@ func (in *IngressBackendService) DeepCopy() *IngressBackendService {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *IngressBackendServicePort) DeepCopyInto(out *IngressBackendServicePort) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new IngressBackendServicePort.
+func (in *IngressBackendServicePort) DeepCopy() *IngressBackendServicePort {
+	if in == nil {
+		return nil
+	}
+	out := new(IngressBackendServicePort)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *IngressList) DeepCopyInto(out *IngressList) {
 	*out = *in
@@ -116,6 +147,36 @@ func (in *IngressList) DeepCopyObject() runtime.Object {
 	return nil
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *IngressPreview) DeepCopyInto(out *IngressPreview) {
+	*out = *in
+	if in.Added != nil {
+		in, out := &in.Added, &out.Added
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Removed != nil {
+		in, out := &in.Removed, &out.Removed
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Shadowed != nil {
+		in, out := &in.Shadowed, &out.Shadowed
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new IngressPreview.
+func (in *IngressPreview) DeepCopy() *IngressPreview {
+	if in == nil {
+		return nil
+	}
+	out := new(IngressPreview)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *IngressRule) DeepCopyInto(out *IngressRule) {
 	*out = *in
@@ -178,6 +239,13 @@ func (in *IngressStatus) DeepCopyInto(out *IngressStatus) {
 			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
 	}
+	if in.Preview != nil {
+		in, out := &in.Preview, &out.Preview
+		*out = make([]IngressPreview, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new IngressStatus.
@@ -252,6 +320,16 @@ func (in *RouterInstanceList) DeepCopyObject() runtime.Object {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *RouterInstanceSpec) DeepCopyInto(out *RouterInstanceSpec) {
 	*out = *in
+	if in.TemplateConfigMaps != nil {
+		in, out := &in.TemplateConfigMaps, &out.TemplateConfigMaps
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.TemplateSecrets != nil {
+		in, out := &in.TemplateSecrets, &out.TemplateSecrets
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
 	in.RouterService.DeepCopyInto(&out.RouterService)
 }
 
@@ -275,6 +353,11 @@ func (in *RouterInstanceStatus) DeepCopyInto(out *RouterInstanceStatus) {
 			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
 	}
+	if in.AppliedRoutingData != nil {
+		in, out := &in.AppliedRoutingData, &out.AppliedRoutingData
+		*out = make([]AppliedRoutingData, len(*in))
+		copy(*out, *in)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RouterInstanceStatus.
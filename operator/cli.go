@@ -0,0 +1,134 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	kasicov1 "github.com/world-direct/kasico/operator/api/v1"
+	"github.com/world-direct/kasico/operator/controllers"
+	"github.com/world-direct/kasico/operator/manifests"
+)
+
+// The helpers of the commands inspecting the routing-data, offline from manifests, or live from the cluster
+
+// newClient returns a client without cache, as the commands only read a few objects once
+func newClient() (client.Client, error) {
+	config, err := restConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	return client.New(config, client.Options{Scheme: scheme})
+}
+
+// listNamespace returns the namespace to list the RouterInstances in, which is empty for all namespaces
+func listNamespace(allNamespaces bool) (string, error) {
+	if allNamespaces {
+		return "", nil
+	}
+
+	return currentNamespace()
+}
+
+// routerRoutingData is the routing-data of a RouterInstance
+type routerRoutingData struct {
+	Router      kasicov1.RouterInstance
+	RoutingData *controllers.RoutingData
+}
+
+// loadRoutingData computes the routing-data from the manifests if filenames are given,
+// otherwise it reads the published routing-data from the cluster
+func loadRoutingData(ctx context.Context, filenames []string, allNamespaces bool, routerName string) ([]routerRoutingData, error) {
+	if len(filenames) > 0 {
+		namespace, err := currentNamespace()
+		if err != nil {
+			return nil, err
+		}
+
+		set, err := manifests.Load(namespace, filenames...)
+		if err != nil {
+			return nil, err
+		}
+
+		return localRoutingData(set, routerName)
+	}
+
+	namespace, err := listNamespace(allNamespaces)
+	if err != nil {
+		return nil, err
+	}
+
+	c, err := newClient()
+	if err != nil {
+		return nil, err
+	}
+
+	return liveRoutingData(ctx, c, namespace, routerName)
+}
+
+// localRoutingData computes the routing-data of the RouterInstances in the manifests,
+// or of the one with the name, like the generator of the operator
+func localRoutingData(set *manifests.Set, routerName string) ([]routerRoutingData, error) {
+	routers := set.RouterInstances
+	if routerName != "" {
+		router, err := set.RouterInstance(routerName)
+		if err != nil {
+			return nil, err
+		}
+		routers = []kasicov1.RouterInstance{*router}
+	}
+
+	if len(routers) == 0 {
+		return nil, fmt.Errorf("no RouterInstance found in the manifests")
+	}
+
+	result := []routerRoutingData{}
+	for _, router := range routers {
+		result = append(result, routerRoutingData{
+			Router:      router,
+			RoutingData: controllers.GetRoutingData(router, set.Ingresses, set.Backends()),
+		})
+	}
+
+	return result, nil
+}
+
+// liveRoutingData reads the published routing-data of the RouterInstances in the namespace,
+// all namespaces if empty, or of the one with the name
+func liveRoutingData(ctx context.Context, c client.Reader, namespace string, routerName string) ([]routerRoutingData, error) {
+	routers := &kasicov1.RouterInstanceList{}
+	err := c.List(ctx, routers, client.InNamespace(namespace))
+	if err != nil {
+		return nil, err
+	}
+
+	result := []routerRoutingData{}
+	for _, router := range routers.Items {
+		if routerName != "" && router.Name != routerName {
+			continue
+		}
+
+		routingData, err := controllers.PublishedRoutingData(ctx, c, &router)
+		if err != nil {
+			return nil, err
+		}
+
+		result = append(result, routerRoutingData{Router: router, RoutingData: routingData})
+	}
+
+	if len(result) == 0 {
+		return nil, fmt.Errorf("no RouterInstance found")
+	}
+
+	return result, nil
+}
+
+func printJSON(out io.Writer, value interface{}) error {
+	encoder := json.NewEncoder(out)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(value)
+}
@@ -0,0 +1,392 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/world-direct/kasico/operator/controllers"
+	"github.com/world-direct/kasico/operator/sidecar"
+)
+
+func newControllerWatchCommand() *cobra.Command {
+	var opts watcherOptions
+	controllerWatchCmd := &cobra.Command{
+		Use:   "watch",
+		Short: "Runs the kasico controller in watch mode",
+		Long: `Watches the ConfigMaps in the --namespace, renders the configuration and reloads kamailio.
+Without --namespace, the namespace of the kubeconfig context is used, or in a pod its own namespace.`,
+		Run: func(cmd *cobra.Command, args []string) {
+			namespace, err := currentNamespace()
+			if err != nil {
+				setupLog.Error(err, "Unable to get the namespace")
+				os.Exit(1)
+			}
+			opts.namespace = namespace
+			if !cmd.Flags().Changed("reload") {
+				opts.sidecar.ReloadActions = defaultReloadActions(opts.sidecar.SqliteFile)
+			}
+			main_watcher(opts)
+		},
+	}
+
+	controllerWatchCmd.Flags().StringVar(&opts.dataConfigMap, "cm-data", controllers.Name_ConfigMap, "The name of the routing-data ConfigMap")
+	controllerWatchCmd.Flags().StringSliceVar(&opts.templatesConfigMaps, "cm-templates", nil, "The names of the template ConfigMaps, layered in order. Defaults to the ConfigMaps of the --router-instance.")
+	controllerWatchCmd.Flags().StringVar(&opts.sidecar.ConfigDirectory, "config-dir", "", "The directory to write the kamailio configuration to")
+	controllerWatchCmd.Flags().StringVar(&opts.sidecar.SqliteFile, "sqlite", "", "The path of the routing database to build, if set")
+	controllerWatchCmd.Flags().StringVar(&opts.sidecar.RPCAddress, "rpc", "", "The kamailio JSON-RPC address (unix:<socket>, fifo:<fifo> or http://<host>:<port>/RPC), to reload changed files. The replies are received in the directory of the socket or FIFO, which must be shared with kamailio.")
+	controllerWatchCmd.Flags().Var(&reloadActionsValue{&opts.sidecar.ReloadActions, false}, "reload", "A reload action 'pattern=command', called if a file matching the pattern has been changed. Can be repeated, and replaces the defaults, which reload dispatcher.list, *.py and the --sqlite database. Actions declared in the '_actions.yaml' template take precedence.")
+	controllerWatchCmd.Flags().BoolVar(&opts.sidecar.AllowExecActions, "allow-exec-actions", false, "Allow the exec and http actions declared in the '_actions.yaml' template, which run commands in the sidecar and call any URL")
+	controllerWatchCmd.Flags().IntVar(&opts.sidecar.RPCRetries, "rpc-retries", 3, "The number of retries of a failed reload command")
+	controllerWatchCmd.Flags().DurationVar(&opts.sidecar.RPCRetryDelay, "rpc-retry-delay", time.Second, "The delay before the first retry of a reload command, doubled on each retry")
+	controllerWatchCmd.Flags().StringVar(&opts.sidecar.CheckCommand, "check", "", "A command to validate the rendered configuration before it is activated, e.g. 'kamailio -c -f {dir}/kamailio.cfg'")
+	controllerWatchCmd.Flags().StringVar(&opts.sidecar.PythonCheck, "python-check", "", "The python interpreter to check the syntax of rendered *.py files, e.g. python3")
+	controllerWatchCmd.Flags().StringVar(&opts.routerInstance, "router-instance", "", "The name of the RouterInstance, to report the result to, and to take the template ConfigMaps from if --cm-templates is not set")
+	controllerWatchCmd.Flags().StringSliceVar(&opts.templatesDirs, "templates-dir", nil, "The directories of the mounted template ConfigMaps, layered in order. If set, the directories are watched instead of the ConfigMaps, without access to the kubernetes API.")
+	controllerWatchCmd.Flags().StringSliceVar(&opts.secrets, "secrets", nil, "The names of Secrets available in the templates as .Secrets, layered in order. Defaults to the Secrets of the --router-instance.")
+	controllerWatchCmd.Flags().StringSliceVar(&opts.secretsDirs, "secrets-dir", nil, "The directories of mounted Secrets available in the templates as .Secrets, used with --templates-dir")
+	controllerWatchCmd.Flags().StringVar(&opts.dataDir, "data-dir", "", "The directory of the mounted routing-data ConfigMap, required with --templates-dir")
+	controllerWatchCmd.Flags().StringVar(&opts.podName, "pod-name", os.Getenv("POD_NAME"), "The name of the own pod, which is annotated with the applied routing-data. Defaults to $POD_NAME.")
+	controllerWatchCmd.Flags().StringVar(&opts.statusAddress, "status-bind-address", ":8082", "The address the /status endpoint binds to, reporting the applied configuration. Empty to disable.")
+	controllerWatchCmd.MarkFlagRequired("config-dir")
+
+	return controllerWatchCmd
+}
+
+func newControllerGenerateCommand() *cobra.Command {
+	var opts generatorOptions
+	controllerGenerateCmd := &cobra.Command{
+		Use:   "generate",
+		Short: "Runs the kasico controller in generation mode",
+		Long: `Renders the configuration once and exits, so it can be used as init container.
+The inputs are read from the ConfigMaps in the --namespace, or from mounted ConfigMap volumes if
+--templates-dir is set. Without --namespace, the namespace of the kubeconfig context is used,
+or in a pod its own namespace.
+The exit code is 0 only if all files have been written.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			namespace, err := currentNamespace()
+			if err != nil {
+				return err
+			}
+			opts.namespace = namespace
+			return main_generator(opts)
+		},
+	}
+
+	controllerGenerateCmd.Flags().StringVar(&opts.dataConfigMap, "cm-data", controllers.Name_ConfigMap, "The name of the routing-data ConfigMap")
+	controllerGenerateCmd.Flags().StringSliceVar(&opts.templatesConfigMaps, "cm-templates", nil, "The names of the template ConfigMaps, layered in order. Defaults to the ConfigMaps of the --router-instance.")
+	controllerGenerateCmd.Flags().StringVar(&opts.routerInstance, "router-instance", "", "The name of the RouterInstance to take the template ConfigMaps and Secrets from, if --cm-templates is not set")
+	controllerGenerateCmd.Flags().StringSliceVar(&opts.templatesDirs, "templates-dir", nil, "The directories of the mounted template ConfigMaps, layered in order, used instead of --cm-templates")
+	controllerGenerateCmd.Flags().StringSliceVar(&opts.secrets, "secrets", nil, "The names of Secrets available in the templates as .Secrets, layered in order")
+	controllerGenerateCmd.Flags().StringSliceVar(&opts.secretsDirs, "secrets-dir", nil, "The directories of mounted Secrets available in the templates as .Secrets, used with --templates-dir")
+	controllerGenerateCmd.Flags().StringVar(&opts.dataDir, "data-dir", "", "The directory of the mounted routing-data ConfigMap, required with --templates-dir")
+	controllerGenerateCmd.Flags().StringVar(&opts.sidecar.ConfigDirectory, "config-dir", "", "The directory to write the kamailio configuration to")
+	controllerGenerateCmd.Flags().StringVar(&opts.sidecar.SqliteFile, "sqlite", "", "The path of the routing database to build, if set")
+	controllerGenerateCmd.Flags().StringVar(&opts.sidecar.CheckCommand, "check", "", "A command to validate the rendered configuration before it is activated, e.g. 'kamailio -c -f {dir}/kamailio.cfg'")
+	controllerGenerateCmd.Flags().StringVar(&opts.sidecar.PythonCheck, "python-check", "", "The python interpreter to check the syntax of rendered *.py files, e.g. python3")
+	controllerGenerateCmd.MarkFlagRequired("config-dir")
+
+	return controllerGenerateCmd
+}
+
+type generatorOptions struct {
+	namespace           string
+	dataConfigMap       string
+	templatesConfigMaps []string
+	templatesDirs       []string
+	secrets             []string
+	secretsDirs         []string
+	dataDir             string
+	routerInstance      string
+	sidecar             sidecar.Options
+}
+
+func main_generator(opts generatorOptions) error {
+	setupLog.Info("Starting Kasico Generator")
+
+	ctx := ctrl.SetupSignalHandler()
+
+	var inputs *sidecar.Inputs
+	var err error
+
+	if len(opts.templatesDirs) > 0 {
+		if opts.dataDir == "" {
+			return fmt.Errorf("--data-dir is required with --templates-dir")
+		}
+
+		source := &sidecar.DirectorySource{
+			TemplatesDirectories: opts.templatesDirs,
+			RoutingDataDirectory: opts.dataDir,
+			SecretsDirectories:   opts.secretsDirs,
+		}
+		inputs, err = source.Load()
+	} else {
+		// the mounted directories can't be mixed with ConfigMaps read from the kubernetes API
+		if opts.dataDir != "" || len(opts.secretsDirs) > 0 {
+			return fmt.Errorf("--data-dir and --secrets-dir can only be used with --templates-dir")
+		}
+
+		if opts.namespace == "" || len(opts.templatesConfigMaps) == 0 && opts.routerInstance == "" {
+			return fmt.Errorf("--namespace and --cm-templates or --router-instance are required without --templates-dir")
+		}
+
+		// the ConfigMaps are read once, so no cache is needed
+		var c client.Client
+		c, err = client.New(getConfigOrDie(), client.Options{Scheme: scheme})
+		if err != nil {
+			return err
+		}
+
+		source := &sidecar.ConfigMapSource{
+			Client:               c,
+			Namespace:            opts.namespace,
+			RoutingDataConfigMap: opts.dataConfigMap,
+			TemplatesConfigMaps:  opts.templatesConfigMaps,
+			Secrets:              opts.secrets,
+			RouterInstance:       opts.routerInstance,
+		}
+		inputs, err = source.Load(ctx)
+	}
+
+	if err != nil {
+		return fmt.Errorf("unable to read the inputs: %w", err)
+	}
+
+	// the generator runs before kamailio is started, so nothing is reloaded
+	opts.sidecar.NoActions = true
+	sc, err := sidecar.New(opts.sidecar)
+	if err != nil {
+		return err
+	}
+
+	written, err := sc.Apply(ctx, inputs)
+	if err != nil {
+		return err
+	}
+
+	if !written {
+		return fmt.Errorf("the configuration has not been written")
+	}
+
+	return nil
+}
+
+type watcherOptions struct {
+	namespace           string
+	dataConfigMap       string
+	templatesConfigMaps []string
+	templatesDirs       []string
+	secrets             []string
+	secretsDirs         []string
+	dataDir             string
+	routerInstance      string
+	podName             string
+	statusAddress       string
+	sidecar             sidecar.Options
+}
+
+// defaultReloadActions reload the files of the sample templates, and the routing database
+func defaultReloadActions(sqliteFile string) []sidecar.ReloadAction {
+	actions := []sidecar.ReloadAction{
+		{Files: []string{"dispatcher.list"}, RPC: "dispatcher.reload"},
+	}
+
+	if sqliteFile != "" {
+		actions = append(actions, sidecar.ReloadAction{Files: []string{filepath.Base(sqliteFile)}, RPC: "htable.reload routes"})
+	}
+
+	return append(actions, sidecar.ReloadAction{Files: []string{"*.py"}, RPC: "app_python3.reload"})
+}
+
+// reloadActionsValue is a pflag.Value for repeated --reload flags
+type reloadActionsValue struct {
+	actions *[]sidecar.ReloadAction
+	changed bool
+}
+
+func (value *reloadActionsValue) String() string {
+	if value.actions == nil || len(*value.actions) == 0 {
+		return ""
+	}
+
+	actions := []string{}
+	for _, action := range *value.actions {
+		actions = append(actions, strings.Join(action.Files, ",")+"="+action.RPC)
+	}
+	return "[" + strings.Join(actions, ",") + "]"
+}
+
+func (value *reloadActionsValue) Set(s string) error {
+	action, err := sidecar.ParseReloadAction(s)
+	if err != nil {
+		return err
+	}
+
+	// the first flag replaces the defaults
+	if !value.changed {
+		*value.actions = nil
+		value.changed = true
+	}
+
+	*value.actions = append(*value.actions, action)
+	return nil
+}
+
+func (value *reloadActionsValue) Type() string {
+	return "stringArray"
+}
+
+func main_watcher(opts watcherOptions) {
+	setupLog.Info("Starting Kasico Watcher")
+
+	if len(opts.templatesDirs) > 0 {
+		main_watcher_directories(opts)
+		return
+	}
+
+	if opts.dataDir != "" || len(opts.secretsDirs) > 0 {
+		setupLog.Error(nil, "--data-dir and --secrets-dir can only be used with --templates-dir")
+		os.Exit(1)
+	}
+
+	if opts.namespace == "" || len(opts.templatesConfigMaps) == 0 && opts.routerInstance == "" {
+		setupLog.Error(nil, "--namespace and --cm-templates or --router-instance are required without --templates-dir")
+		os.Exit(1)
+	}
+
+	// the watcher only needs the ConfigMaps in its own namespace
+	mgr, err := ctrl.NewManager(getConfigOrDie(), ctrl.Options{
+		Scheme:             scheme,
+		Namespace:          opts.namespace,
+		MetricsBindAddress: "0",
+	})
+	if err != nil {
+		setupLog.Error(err, "unable to start manager")
+		os.Exit(1)
+	}
+
+	sc, err := sidecar.New(opts.sidecar)
+	if err != nil {
+		setupLog.Error(err, "unable to create the sidecar")
+		os.Exit(1)
+	}
+
+	reporters := sidecar.Reporters{}
+
+	if opts.statusAddress != "" {
+		statusServer := &sidecar.StatusServer{Address: opts.statusAddress}
+		reporters = append(reporters, statusServer)
+
+		if err := mgr.Add(statusServer); err != nil {
+			setupLog.Error(err, "unable to add the status server")
+			os.Exit(1)
+		}
+	}
+
+	if opts.podName != "" {
+		reporters = append(reporters, &sidecar.PodReporter{
+			Client: mgr.GetClient(),
+			Pod:    types.NamespacedName{Namespace: opts.namespace, Name: opts.podName},
+		})
+	}
+
+	sc.Reporter = reporters
+
+	// the Secrets are not cached, but read and watched by name
+	secretsClient, err := client.NewWithWatch(mgr.GetConfig(), client.Options{Scheme: scheme})
+	if err != nil {
+		setupLog.Error(err, "unable to create the Secrets client")
+		os.Exit(1)
+	}
+
+	source := &sidecar.ConfigMapSource{
+		Client:               mgr.GetClient(),
+		Cache:                mgr.GetCache(),
+		SecretsClient:        secretsClient,
+		Sidecar:              sc,
+		Namespace:            opts.namespace,
+		RoutingDataConfigMap: opts.dataConfigMap,
+		TemplatesConfigMaps:  opts.templatesConfigMaps,
+		Secrets:              opts.secrets,
+		RouterInstance:       opts.routerInstance,
+	}
+
+	if err := mgr.Add(source); err != nil {
+		setupLog.Error(err, "unable to add the ConfigMap source")
+		os.Exit(1)
+	}
+
+	if err := mgr.Start(ctrl.SetupSignalHandler()); err != nil {
+		setupLog.Error(err, "problem running manager")
+		os.Exit(1)
+	}
+}
+
+// main_watcher_directories watches mounted ConfigMap volumes, so no kubernetes client is created
+func main_watcher_directories(opts watcherOptions) {
+	if opts.dataDir == "" {
+		setupLog.Error(nil, "--data-dir is required with --templates-dir")
+		os.Exit(1)
+	}
+
+	if opts.routerInstance != "" {
+		setupLog.Error(nil, "--router-instance needs access to the kubernetes API, and can't be used with --templates-dir")
+		os.Exit(1)
+	}
+
+	sc, err := sidecar.New(opts.sidecar)
+	if err != nil {
+		setupLog.Error(err, "unable to create the sidecar")
+		os.Exit(1)
+	}
+
+	ctx := ctrl.SetupSignalHandler()
+
+	if opts.statusAddress != "" {
+		statusServer := &sidecar.StatusServer{Address: opts.statusAddress}
+		sc.Reporter = statusServer
+
+		go func() {
+			if err := statusServer.Start(ctx); err != nil {
+				setupLog.Error(err, "problem running the status server")
+				os.Exit(1)
+			}
+		}()
+	}
+
+	source := &sidecar.DirectorySource{
+		TemplatesDirectories: opts.templatesDirs,
+		RoutingDataDirectory: opts.dataDir,
+		SecretsDirectories:   opts.secretsDirs,
+		Sidecar:              sc,
+	}
+
+	if err := source.Start(ctx); err != nil {
+		setupLog.Error(err, "problem watching the directories")
+		os.Exit(1)
+	}
+}
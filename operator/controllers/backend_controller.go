@@ -0,0 +1,152 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"sync"
+
+	corev1 "k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/util/workqueue"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	ctrllog "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+
+	kasicov1 "github.com/world-direct/kasico/operator/api/v1"
+)
+
+// BackendReconciler watches the Services (and their EndpointSlices) referenced by
+// Ingress backends, and notifies the generator for the affected ingress classes.
+// The EndpointSlices are cached for the whole cluster, so they are only watched after a
+// RouterInstance with ResolveEndpoints has been seen.
+type BackendReconciler struct {
+	client.Client
+	Scheme    *runtime.Scheme
+	Generator Generator
+
+	controller             controller.Controller
+	mu                     sync.Mutex
+	watchingEndpointSlices bool
+}
+
+//+kubebuilder:rbac:groups=core,resources=services,verbs=get;list;watch
+//+kubebuilder:rbac:groups=discovery.k8s.io,resources=endpointslices,verbs=get;list;watch
+
+// Reconcile is called with the name of the Service, which has been changed or deleted.
+// We don't need the Service itself, just the Ingresses referencing it.
+func (r *BackendReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+
+	log := ctrllog.FromContext(ctx)
+	log.V(2).Info("Reconcile Backend")
+
+	ingresses := &kasicov1.IngressList{}
+	err := r.List(ctx, ingresses,
+		client.InNamespace(req.Namespace),
+		client.MatchingFields{Index_IngressBackendService: req.Name})
+	if err != nil {
+		log.Error(err, "Failed to list Ingresses for Service")
+		return ctrl.Result{}, err
+	}
+
+	classes := []string{}
+	seen := map[string]bool{}
+	for _, ingress := range ingresses.Items {
+		if !seen[ingress.Spec.IngressClassName] {
+			seen[ingress.Spec.IngressClassName] = true
+			classes = append(classes, ingress.Spec.IngressClassName)
+		}
+	}
+
+	if len(classes) > 0 {
+		r.Generator.OnIngressClassesChanged(ctx, "Service "+req.NamespacedName.String(), classes)
+	}
+
+	return ctrl.Result{}, nil
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *BackendReconciler) SetupWithManager(mgr ctrl.Manager) error {
+
+	err := mgr.GetFieldIndexer().IndexField(context.Background(), &kasicov1.Ingress{}, Index_IngressBackendService,
+		func(obj client.Object) []string {
+			return IngressBackendServices(obj.(*kasicov1.Ingress))
+		})
+	if err != nil {
+		return err
+	}
+
+	r.controller, err = ctrl.NewControllerManagedBy(mgr).
+		Named("backend").
+		For(&corev1.Service{}).
+		Watches(&source.Kind{Type: &kasicov1.RouterInstance{}}, handler.Funcs{
+			CreateFunc: func(e event.CreateEvent, _ workqueue.RateLimitingInterface) {
+				r.onRouterInstance(e.Object)
+			},
+			UpdateFunc: func(e event.UpdateEvent, _ workqueue.RateLimitingInterface) {
+				r.onRouterInstance(e.ObjectNew)
+			},
+		}).
+		Build(r)
+
+	return err
+}
+
+// onRouterInstance starts the watch of the EndpointSlices, once a RouterInstance resolves the endpoints.
+// The watch is kept, even if no RouterInstance resolves the endpoints anymore.
+func (r *BackendReconciler) onRouterInstance(obj client.Object) {
+	router, ok := obj.(*kasicov1.RouterInstance)
+	if !ok || !router.Spec.ResolveEndpoints {
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.watchingEndpointSlices {
+		return
+	}
+
+	err := r.controller.Watch(&source.Kind{Type: &discoveryv1.EndpointSlice{}},
+		handler.EnqueueRequestsFromMapFunc(endpointSliceToService))
+	if err != nil {
+		// retried with the next event of a RouterInstance
+		ctrllog.Log.WithName("backend").Error(err, "Unable to watch the EndpointSlices")
+		return
+	}
+
+	r.watchingEndpointSlices = true
+}
+
+// endpointSliceToService maps the EndpointSlice to the request of the owning Service
+func endpointSliceToService(obj client.Object) []reconcile.Request {
+	name := obj.GetLabels()[discoveryv1.LabelServiceName]
+	if name == "" {
+		return nil
+	}
+
+	return []reconcile.Request{
+		{NamespacedName: types.NamespacedName{Namespace: obj.GetNamespace(), Name: name}},
+	}
+}
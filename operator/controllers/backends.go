@@ -0,0 +1,128 @@
+package controllers
+
+import (
+	"net"
+	"sort"
+	"strconv"
+
+	kasicov1 "github.com/world-direct/kasico/operator/api/v1"
+	corev1 "k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
+)
+
+// Backends holds the Services and EndpointSlices referenced by Ingress backends.
+// It is passed to GetRoutingData to drop rules to missing Services, and to resolve
+// the endpoints if requested by the RouterInstance.
+// A nil *Backends disables the lookup, so all rules are mapped unchanged.
+type Backends struct {
+	Services       []corev1.Service
+	EndpointSlices []discoveryv1.EndpointSlice
+}
+
+// HasService returns true if the Service exists
+func (b *Backends) HasService(namespace string, name string) bool {
+	if b == nil {
+		return true
+	}
+
+	for _, service := range b.Services {
+		if service.Namespace == namespace && service.Name == name {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Endpoints returns the sorted addresses of all ready endpoints of the Service.
+// The port of the EndpointSlice, which has the name of the referenced Service port,
+// is appended to the address. Without a reference, the first port of the Service is used.
+func (b *Backends) Endpoints(namespace string, name string, servicePort kasicov1.IngressBackendServicePort) []string {
+	if b == nil {
+		return nil
+	}
+
+	// the EndpointSlices name their ports like the Service, but contain the target ports
+	portName, hasPort := b.servicePortName(namespace, name, servicePort)
+
+	seen := map[string]bool{}
+	endpoints := []string{}
+
+	for _, slice := range b.EndpointSlices {
+		if slice.Namespace != namespace || slice.Labels[discoveryv1.LabelServiceName] != name {
+			continue
+		}
+
+		port := ""
+		for _, endpointPort := range slice.Ports {
+			if hasPort && endpointPort.Port != nil && (endpointPort.Name == nil && portName == "" || endpointPort.Name != nil && *endpointPort.Name == portName) {
+				port = strconv.Itoa(int(*endpointPort.Port))
+				break
+			}
+		}
+
+		for _, endpoint := range slice.Endpoints {
+			if endpoint.Conditions.Ready != nil && !*endpoint.Conditions.Ready {
+				continue
+			}
+
+			for _, address := range endpoint.Addresses {
+				// IPv6 addresses are enclosed in brackets, if a port is appended
+				if port != "" {
+					address = net.JoinHostPort(address, port)
+				}
+
+				if !seen[address] {
+					seen[address] = true
+					endpoints = append(endpoints, address)
+				}
+			}
+		}
+	}
+
+	sort.Strings(endpoints)
+	return endpoints
+}
+
+// servicePortName returns the name of the referenced port of the Service,
+// or false if the Service or the port doesn't exist
+func (b *Backends) servicePortName(namespace string, name string, servicePort kasicov1.IngressBackendServicePort) (string, bool) {
+	for _, service := range b.Services {
+		if service.Namespace != namespace || service.Name != name {
+			continue
+		}
+
+		// without ports in the Service, the unnamed port of the EndpointSlices is used
+		if len(service.Spec.Ports) == 0 {
+			return "", servicePort.Name == "" && servicePort.Number == 0
+		}
+
+		for _, port := range service.Spec.Ports {
+			switch {
+			case servicePort.Name != "" && port.Name == servicePort.Name,
+				servicePort.Number != 0 && port.Port == servicePort.Number,
+				servicePort.Name == "" && servicePort.Number == 0:
+				return port.Name, true
+			}
+		}
+	}
+
+	return "", false
+}
+
+// IngressBackendServices returns the names of all Services referenced by the Ingress.
+// This is used for the Index_IngressBackendService field index.
+func IngressBackendServices(ingress *kasicov1.Ingress) []string {
+	names := []string{}
+	seen := map[string]bool{}
+
+	for _, rule := range ingress.Spec.Rules {
+		name := rule.Backend.Service.Name
+		if name != "" && !seen[name] {
+			seen[name] = true
+			names = append(names, name)
+		}
+	}
+
+	return names
+}
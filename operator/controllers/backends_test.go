@@ -0,0 +1,86 @@
+package controllers
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	kasicov1 "github.com/world-direct/kasico/operator/api/v1"
+	corev1 "k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+)
+
+func TestBackends_EndpointsIPv6(t *testing.T) {
+	port := int32(5060)
+	backends := &Backends{
+		Services: []corev1.Service{{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "a", Name: "pbx"},
+			Spec:       corev1.ServiceSpec{Ports: []corev1.ServicePort{{Port: 5060}}},
+		}},
+		EndpointSlices: []discoveryv1.EndpointSlice{{
+			ObjectMeta:  metav1.ObjectMeta{Namespace: "a", Name: "pbx-v6", Labels: map[string]string{discoveryv1.LabelServiceName: "pbx"}},
+			AddressType: discoveryv1.AddressTypeIPv6,
+			Ports:       []discoveryv1.EndpointPort{{Port: &port}},
+			Endpoints:   []discoveryv1.Endpoint{{Addresses: []string{"2001:db8::1"}}, {Addresses: []string{"192.0.2.1"}}},
+		}},
+	}
+
+	assert.Equal(t, []string{"192.0.2.1:5060", "[2001:db8::1]:5060"}, backends.Endpoints("a", "pbx", kasicov1.IngressBackendServicePort{}))
+}
+
+func TestBackends_EndpointsMultiPort(t *testing.T) {
+	sip, tls, http := "sip", "sip-tls", "http"
+	sipPort, tlsPort, httpPort := int32(15060), int32(15061), int32(8080)
+	backends := &Backends{
+		Services: []corev1.Service{{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "a", Name: "pbx"},
+			Spec: corev1.ServiceSpec{Ports: []corev1.ServicePort{
+				{Name: http, Port: 80}, {Name: sip, Port: 5060}, {Name: tls, Port: 5061},
+			}},
+		}},
+		EndpointSlices: []discoveryv1.EndpointSlice{{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "a", Name: "pbx-1", Labels: map[string]string{discoveryv1.LabelServiceName: "pbx"}},
+			// the order of the ports in the slice is not the order of the Service
+			Ports: []discoveryv1.EndpointPort{
+				{Name: &tls, Port: &tlsPort}, {Name: &sip, Port: &sipPort}, {Name: &http, Port: &httpPort},
+			},
+			Endpoints: []discoveryv1.Endpoint{{Addresses: []string{"192.0.2.1"}}},
+		}},
+	}
+
+	assert.Equal(t, []string{"192.0.2.1:15060"}, backends.Endpoints("a", "pbx", kasicov1.IngressBackendServicePort{Name: "sip"}))
+	assert.Equal(t, []string{"192.0.2.1:15061"}, backends.Endpoints("a", "pbx", kasicov1.IngressBackendServicePort{Number: 5061}))
+	assert.Equal(t, []string{"192.0.2.1:8080"}, backends.Endpoints("a", "pbx", kasicov1.IngressBackendServicePort{}))
+
+	// an unknown port doesn't guess another one
+	assert.Equal(t, []string{"192.0.2.1"}, backends.Endpoints("a", "pbx", kasicov1.IngressBackendServicePort{Name: "rtp"}))
+}
+
+// watchCountingController counts the watches added to it
+type watchCountingController struct {
+	controller.Controller
+	watches int
+}
+
+func (c *watchCountingController) Watch(src source.Source, eventHandler handler.EventHandler, predicates ...predicate.Predicate) error {
+	c.watches++
+	return nil
+}
+
+func TestBackendReconciler_WatchEndpointSlices(t *testing.T) {
+	c := &watchCountingController{}
+	r := &BackendReconciler{controller: c}
+
+	// the EndpointSlices are only watched, once they are needed
+	r.onRouterInstance(&kasicov1.RouterInstance{})
+	assert.Equal(t, 0, c.watches)
+
+	resolving := &kasicov1.RouterInstance{Spec: kasicov1.RouterInstanceSpec{ResolveEndpoints: true}}
+	r.onRouterInstance(resolving)
+	r.onRouterInstance(resolving)
+	assert.Equal(t, 1, c.watches)
+}
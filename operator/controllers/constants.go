@@ -3,7 +3,64 @@ package controllers
 const Name_Daemonset = "kasico-router"
 const Name_Service = "kasico-router"
 const Name_Container_Kamailio = "kamailio"
+const Name_Container_Controller = "controller"
+
+// Name_Container_Generator is the init container rendering the configuration, before kamailio is started
+const Name_Container_Generator = "generator"
+
+// Default_RouterImage is the image of the kamailio container, which reads the rendered /etc/kamailio/kamailio.cfg
+const Default_RouterImage = "kamailio/kamailio:5.6.2-bullseye"
+
+// Env_ControllerImage is set to the image of the operator in its Deployment, and is the default
+// of the image of the kasico controller sidecar
+const Env_ControllerImage = "KASICO_CONTROLLER_IMAGE"
+
+// Default_ControllerImage is the image of the kasico controller sidecar, if Env_ControllerImage is not set
+const Default_ControllerImage = "controller:latest"
+
+// Name_ServiceAccount is the service account of the router pods, bound to the Name_ControllerRole
+const Name_ServiceAccount = "kasico-router"
+
+// Name_ControllerRole is the ClusterRole with the permissions of the kasico controller sidecar
+const Name_ControllerRole = "kasico-controller-role"
+
+// The shared volumes of the router pods: the rendered configuration and the kamailio RPC socket
+const Name_Volume_Config = "config"
+const Name_Volume_Run = "run"
+const Path_Config = "/etc/kamailio"
+const Path_Run = "/run/kamailio"
 const Name_ConfigMap = "routing-data"
 const Name_RouningDataJson = "routing-data.json"
 
 const Name_AnnotationRoutingDataHash = "kasico.routing-data.hash"
+const Name_AnnotationRoutingDataGeneration = "kasico.routing-data.generation"
+const Name_AnnotationRoutingRevision = "kasico.routing-data.revision"
+const Name_AnnotationRoutingTriggeredBy = "kasico.routing-data.triggered-by"
+
+// Name_AnnotationDryRun on an Ingress makes the generator compute a preview, without publishing it
+const Name_AnnotationDryRun = "kasico.dry-run"
+
+// Name_LabelRoutingRevision marks the revision ConfigMaps, the value is the name of the RouterInstance
+const Name_LabelRoutingRevision = "kasico.routing-revision"
+
+// Index_IngressBackendService indexes Ingresses by the names of their backend services
+const Index_IngressBackendService = "spec.rules.backend.service.name"
+
+// Name_AnnotationAppliedHash and Name_AnnotationAppliedGeneration are set by the sidecar on its router pod,
+// to report the routing-data it applied
+const Name_AnnotationAppliedHash = "kasico.routing-data.applied-hash"
+const Name_AnnotationAppliedGeneration = "kasico.routing-data.applied-generation"
+
+// Name_AnnotationConfigError is set by the sidecar on its router pod, if the last configuration
+// has been rejected, with the reason and the error. It is removed, once a configuration is applied.
+const Name_AnnotationConfigError = "kasico.config.error"
+
+// Condition_ConfigValid on the RouterInstance is false, if a router pod rejected its configuration
+const Condition_ConfigValid = "ConfigValid"
+
+// Condition_ConfigApplied on the RouterInstance is true, if all router pods applied the published routing-data
+const Condition_ConfigApplied = "ConfigApplied"
+
+// Condition_RevisionPinned on the RouterInstance is true, if the pinned revision is published,
+// and false if it doesn't exist
+const Condition_RevisionPinned = "RevisionPinned"
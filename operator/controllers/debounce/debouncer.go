@@ -19,7 +19,14 @@ import (
 // The debounced function can be invoked with different functions, if needed,
 // the last one will win.
 func New(after time.Duration) func(f func()) {
-	d := &debouncer{after: after}
+	return NewWithMaxWait(after, 0)
+}
+
+// NewWithMaxWait returns a debounced function like New, which calls the function
+// at the latest maxWait after the first call, even if it is still being called.
+// A maxWait of 0 waits without limit.
+func NewWithMaxWait(after time.Duration, maxWait time.Duration) func(f func()) {
+	d := &debouncer{after: after, maxWait: maxWait}
 
 	return func(f func()) {
 		d.add(f)
@@ -27,9 +34,13 @@ func New(after time.Duration) func(f func()) {
 }
 
 type debouncer struct {
-	mu    sync.Mutex
-	after time.Duration
-	timer *time.Timer
+	mu      sync.Mutex
+	after   time.Duration
+	maxWait time.Duration
+	timer   *time.Timer
+
+	// first is the time of the first call since the function has been called the last time
+	first time.Time
 }
 
 func (d *debouncer) add(f func()) {
@@ -39,5 +50,24 @@ func (d *debouncer) add(f func()) {
 	if d.timer != nil {
 		d.timer.Stop()
 	}
-	d.timer = time.AfterFunc(d.after, f)
+
+	now := time.Now()
+	if d.first.IsZero() {
+		d.first = now
+	}
+
+	after := d.after
+	if d.maxWait > 0 {
+		if remaining := d.first.Add(d.maxWait).Sub(now); remaining < after {
+			after = remaining
+		}
+	}
+
+	d.timer = time.AfterFunc(after, func() {
+		d.mu.Lock()
+		d.first = time.Time{}
+		d.mu.Unlock()
+
+		f()
+	})
 }
@@ -0,0 +1,28 @@
+package debounce
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewWithMaxWait(t *testing.T) {
+	var calls int32
+	f := func() { atomic.AddInt32(&calls, 1) }
+
+	debounced := NewWithMaxWait(time.Millisecond*50, time.Millisecond*100)
+	for i := 0; i < 15; i++ {
+		debounced(f)
+		time.Sleep(time.Millisecond * 20)
+	}
+
+	// without the max wait, the function would not have been called yet
+	assert.GreaterOrEqual(t, atomic.LoadInt32(&calls), int32(2))
+
+	time.Sleep(time.Millisecond * 100)
+	called := atomic.LoadInt32(&calls)
+	time.Sleep(time.Millisecond * 100)
+	assert.Equal(t, called, atomic.LoadInt32(&calls))
+}
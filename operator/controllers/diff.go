@@ -0,0 +1,178 @@
+package controllers
+
+import (
+	"reflect"
+	"strings"
+)
+
+// The routers look up the rule of a request in two tables, by the domain and by the headnumber,
+// see MatchRoute. A rule with a domain and a headnumber is an entry of both tables.
+// Rules with the same domain, or the same headnumber, are conflicting: the first rule in the
+// canonical order wins the entry, and shadows the following rules for this domain or headnumber.
+
+// The match types of the entries of the routers' lookup tables
+const (
+	MatchTypeDomain     = "domain"
+	MatchTypeHeadnumber = "headnumber"
+)
+
+// String returns a short human readable representation of the rule
+func (rule RoutingRule) String() string {
+	s := rule.Domain + " " + rule.Headnumber + " -> " + rule.Backend
+	if len(rule.Endpoints) > 0 {
+		s += " [" + strings.Join(rule.Endpoints, ",") + "]"
+	}
+
+	return s + " (" + rule.Owner + ")"
+}
+
+// RouteEntry is an entry of a lookup table of the routers
+type RouteEntry struct {
+	// MatchType is MatchTypeDomain or MatchTypeHeadnumber
+	MatchType string
+
+	// Key is the domain or the headnumber
+	Key  string
+	Rule RoutingRule
+}
+
+// String returns a short human readable representation of the entry
+func (entry RouteEntry) String() string {
+	return entry.MatchType + " " + entry.Key + ": " + entry.Rule.String()
+}
+
+// ShadowedRule is a rule which is not used for a domain or headnumber, because another rule has it as well
+type ShadowedRule struct {
+	Rule RoutingRule
+	By   RoutingRule
+
+	// MatchType and Key are the domain or headnumber the rule lost
+	MatchType string
+	Key       string
+}
+
+// routeEntries returns the entries of the rule in the lookup tables, if it wins them
+func (rule RoutingRule) routeEntries() []RouteEntry {
+	entries := []RouteEntry{}
+	if rule.Domain != "" {
+		entries = append(entries, RouteEntry{MatchType: MatchTypeDomain, Key: rule.Domain, Rule: rule})
+	}
+	if rule.Headnumber != "" {
+		entries = append(entries, RouteEntry{MatchType: MatchTypeHeadnumber, Key: rule.Headnumber, Rule: rule})
+	}
+
+	return entries
+}
+
+// ResolveRoutes resolves the conflicts between the rules like the routers do, the rules need to be
+// in canonical order. It returns the entries of the domain and headnumber tables in the order of
+// the rules, and the rules shadowed for a domain or headnumber.
+func ResolveRoutes(rules []RoutingRule) ([]RouteEntry, []ShadowedRule) {
+	entries := []RouteEntry{}
+	shadowed := []ShadowedRule{}
+	winners := map[string]int{}
+
+	for _, rule := range rules {
+		for _, entry := range rule.routeEntries() {
+			key := entry.MatchType + "/" + entry.Key
+			if i, exists := winners[key]; exists {
+				shadowed = append(shadowed, ShadowedRule{Rule: rule, By: entries[i].Rule, MatchType: entry.MatchType, Key: entry.Key})
+				continue
+			}
+
+			winners[key] = len(entries)
+			entries = append(entries, entry)
+		}
+	}
+
+	return entries, shadowed
+}
+
+// RuleChange is an entry of the lookup tables, with a different owner or backend
+type RuleChange struct {
+	Old RouteEntry
+	New RouteEntry
+}
+
+// RoutingDataDiff is the semantic difference between the lookup tables of the routers
+// of two RoutingData, see ResolveRoutes
+type RoutingDataDiff struct {
+	Added   []RouteEntry
+	Removed []RouteEntry
+
+	// Moved are entries now owned by another Ingress
+	Moved []RuleChange
+
+	// Changed are entries with the same owner, but different backends
+	Changed []RuleChange
+}
+
+// Empty returns true if there are no differences
+func (diff *RoutingDataDiff) Empty() bool {
+	return len(diff.Added) == 0 && len(diff.Removed) == 0 && len(diff.Moved) == 0 && len(diff.Changed) == 0
+}
+
+// WithoutEndpoints returns a copy of the RoutingData without the endpoints of the rules,
+// so routing-data computed without EndpointSlices can be compared. A nil RoutingData stays nil.
+func (rd *RoutingData) WithoutEndpoints() *RoutingData {
+	if rd == nil {
+		return nil
+	}
+
+	result := *rd
+	result.Rules = make([]RoutingRule, len(rd.Rules))
+	for i, rule := range rd.Rules {
+		rule.Endpoints = nil
+		result.Rules[i] = rule
+	}
+
+	return &result
+}
+
+// DiffRoutingData compares the lookup tables of the routers of old and new, so only the
+// rules used by the routers make a difference. A nil RoutingData is treated as empty.
+func DiffRoutingData(old *RoutingData, new *RoutingData) *RoutingDataDiff {
+	diff := &RoutingDataDiff{}
+
+	oldEntries := []RouteEntry{}
+	if old != nil {
+		oldEntries, _ = ResolveRoutes(old.Rules)
+	}
+
+	newEntries := []RouteEntry{}
+	if new != nil {
+		newEntries, _ = ResolveRoutes(new.Rules)
+	}
+
+	key := func(entry *RouteEntry) string {
+		return entry.MatchType + "/" + entry.Key
+	}
+
+	oldByKey := map[string]RouteEntry{}
+	for _, entry := range oldEntries {
+		oldByKey[key(&entry)] = entry
+	}
+
+	newKeys := map[string]bool{}
+	for _, entry := range newEntries {
+		newKeys[key(&entry)] = true
+
+		oldEntry, exists := oldByKey[key(&entry)]
+		switch {
+		case !exists:
+			diff.Added = append(diff.Added, entry)
+		case oldEntry.Rule.Owner != entry.Rule.Owner:
+			diff.Moved = append(diff.Moved, RuleChange{Old: oldEntry, New: entry})
+		case oldEntry.Rule.Backend != entry.Rule.Backend || !reflect.DeepEqual(oldEntry.Rule.Endpoints, entry.Rule.Endpoints):
+			diff.Changed = append(diff.Changed, RuleChange{Old: oldEntry, New: entry})
+		}
+	}
+
+	for _, entry := range oldEntries {
+		if !newKeys[key(&entry)] {
+			diff.Removed = append(diff.Removed, entry)
+		}
+	}
+
+	return diff
+}
@@ -0,0 +1,162 @@
+package controllers
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	kasicov1 "github.com/world-direct/kasico/operator/api/v1"
+)
+
+func TestResolveRoutes(t *testing.T) {
+	rules := []RoutingRule{
+		{Domain: "a.example.org", Headnumber: "+431", Owner: "default/a", Backend: "s1.default"},
+		{Domain: "a.example.org", Headnumber: "+431", Owner: "default/b", Backend: "s2.default"},
+		{Domain: "a.example.org", Headnumber: "+4312", Owner: "default/b", Backend: "s2.default"},
+	}
+
+	entries, shadowed := ResolveRoutes(rules)
+	assert.Equal(t, []RouteEntry{
+		{MatchType: MatchTypeDomain, Key: "a.example.org", Rule: rules[0]},
+		{MatchType: MatchTypeHeadnumber, Key: "+431", Rule: rules[0]},
+		{MatchType: MatchTypeHeadnumber, Key: "+4312", Rule: rules[2]},
+	}, entries)
+	assert.Equal(t, []ShadowedRule{
+		{Rule: rules[1], By: rules[0], MatchType: MatchTypeDomain, Key: "a.example.org"},
+		{Rule: rules[1], By: rules[0], MatchType: MatchTypeHeadnumber, Key: "+431"},
+		{Rule: rules[2], By: rules[0], MatchType: MatchTypeDomain, Key: "a.example.org"},
+	}, shadowed)
+
+	// a headnumber is shadowed independent of the domain
+	rules = []RoutingRule{
+		{Headnumber: "+43", Owner: "default/a", Backend: "s1.default"},
+		{Domain: "b.example.org", Headnumber: "+43", Owner: "default/b", Backend: "s2.default"},
+	}
+
+	entries, shadowed = ResolveRoutes(rules)
+	assert.Equal(t, []RouteEntry{
+		{MatchType: MatchTypeHeadnumber, Key: "+43", Rule: rules[0]},
+		{MatchType: MatchTypeDomain, Key: "b.example.org", Rule: rules[1]},
+	}, entries)
+	assert.Equal(t, []ShadowedRule{{Rule: rules[1], By: rules[0], MatchType: MatchTypeHeadnumber, Key: "+43"}}, shadowed)
+}
+
+func TestDiffRoutingData(t *testing.T) {
+	rule := func(headnumber string, owner string, backend string, endpoints ...string) RoutingRule {
+		return RoutingRule{Headnumber: headnumber, Owner: owner, Backend: backend, Endpoints: endpoints}
+	}
+	entry := func(rule RoutingRule) RouteEntry {
+		return RouteEntry{MatchType: MatchTypeHeadnumber, Key: rule.Headnumber, Rule: rule}
+	}
+
+	domainRule := RoutingRule{Domain: "a.example.org", Headnumber: "+431", Owner: "default/a", Backend: "s1.default"}
+	sameDomainRule := RoutingRule{Domain: "a.example.org", Headnumber: "+432", Owner: "default/b", Backend: "s2.default"}
+
+	tests := []struct {
+		name string
+		old  []RoutingRule
+		new  []RoutingRule
+		want RoutingDataDiff
+	}{
+		{
+			name: "added",
+			old:  []RoutingRule{rule("+431", "default/a", "s1.default")},
+			new:  []RoutingRule{rule("+431", "default/a", "s1.default"), rule("+432", "default/a", "s1.default")},
+			want: RoutingDataDiff{Added: []RouteEntry{entry(rule("+432", "default/a", "s1.default"))}},
+		},
+		{
+			name: "removed",
+			old:  []RoutingRule{rule("+431", "default/a", "s1.default"), rule("+432", "default/a", "s1.default")},
+			new:  []RoutingRule{rule("+431", "default/a", "s1.default")},
+			want: RoutingDataDiff{Removed: []RouteEntry{entry(rule("+432", "default/a", "s1.default"))}},
+		},
+		{
+			name: "moved",
+			old:  []RoutingRule{rule("+431", "default/a", "s1.default")},
+			new:  []RoutingRule{rule("+431", "default/b", "s1.default")},
+			want: RoutingDataDiff{Moved: []RuleChange{{Old: entry(rule("+431", "default/a", "s1.default")), New: entry(rule("+431", "default/b", "s1.default"))}}},
+		},
+		{
+			name: "changed backend",
+			old:  []RoutingRule{rule("+431", "default/a", "s1.default")},
+			new:  []RoutingRule{rule("+431", "default/a", "s2.default")},
+			want: RoutingDataDiff{Changed: []RuleChange{{Old: entry(rule("+431", "default/a", "s1.default")), New: entry(rule("+431", "default/a", "s2.default"))}}},
+		},
+		{
+			name: "changed endpoints",
+			old:  []RoutingRule{rule("+431", "default/a", "s1.default", "192.0.2.1:5060")},
+			new:  []RoutingRule{rule("+431", "default/a", "s1.default", "192.0.2.2:5060")},
+			want: RoutingDataDiff{Changed: []RuleChange{{Old: entry(rule("+431", "default/a", "s1.default", "192.0.2.1:5060")), New: entry(rule("+431", "default/a", "s1.default", "192.0.2.2:5060"))}}},
+		},
+		{
+			name: "shadowed rules are ignored",
+			old:  []RoutingRule{rule("+431", "default/a", "s1.default")},
+			new:  []RoutingRule{rule("+431", "default/a", "s1.default"), rule("+431", "default/b", "s2.default")},
+		},
+		{
+			// the domain stays routed to the first rule, only the headnumber is added
+			name: "added with a shadowed domain",
+			old:  []RoutingRule{domainRule},
+			new:  []RoutingRule{domainRule, sameDomainRule},
+			want: RoutingDataDiff{Added: []RouteEntry{{MatchType: MatchTypeHeadnumber, Key: "+432", Rule: sameDomainRule}}},
+		},
+		{
+			name: "unchanged",
+			old:  []RoutingRule{rule("+431", "default/a", "s1.default")},
+			new:  []RoutingRule{rule("+431", "default/a", "s1.default")},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			diff := DiffRoutingData(&RoutingData{Rules: test.old}, &RoutingData{Rules: test.new})
+			assert.Equal(t, test.want, *diff)
+			assert.Equal(t, test.want.Empty(), diff.Empty())
+		})
+	}
+}
+
+func TestDiffRoutingData_WithoutEndpoints(t *testing.T) {
+	old := &RoutingData{Rules: []RoutingRule{{Headnumber: "+431", Owner: "default/a", Backend: "s1.default", Endpoints: []string{"192.0.2.1:5060"}}}}
+	new := &RoutingData{Rules: []RoutingRule{{Headnumber: "+431", Owner: "default/a", Backend: "s1.default"}}}
+
+	assert.False(t, DiffRoutingData(old, new).Empty())
+	assert.True(t, DiffRoutingData(old.WithoutEndpoints(), new.WithoutEndpoints()).Empty())
+	assert.Equal(t, []string{"192.0.2.1:5060"}, old.Rules[0].Endpoints)
+	assert.Nil(t, (*RoutingData)(nil).WithoutEndpoints())
+}
+
+func TestProposedRoutingData(t *testing.T) {
+	router := kasicov1.RouterInstance{Spec: kasicov1.RouterInstanceSpec{IngressClassName: "default"}}
+
+	dryRun := testIngress("default", "b", "default", testIngressRule("a.example.org", "+432", "s2"))
+	dryRun.Annotations = map[string]string{Name_AnnotationDryRun: "true"}
+	ingresses := []kasicov1.Ingress{testIngress("default", "a", "default", testIngressRule("a.example.org", "+431", "s1")), dryRun}
+
+	// the published rules of the dry-run Ingress are kept, so they make no difference
+	published := &RoutingData{Rules: []RoutingRule{
+		{Domain: "a.example.org", Headnumber: "+431", Owner: "default/a", Backend: "s1.default"},
+		{Domain: "a.example.org", Headnumber: "+433", Owner: "default/b", Backend: "s2.default"},
+	}}
+
+	proposed := ProposedRoutingData(router, ingresses, nil, published)
+	assert.True(t, DiffRoutingData(published, proposed).Empty())
+}
+
+func TestPreviewIngress(t *testing.T) {
+	router := &kasicov1.RouterInstance{Spec: kasicov1.RouterInstanceSpec{IngressClassName: "default"}}
+
+	existing := testIngress("default", "a", "default", testIngressRule("a.example.org", "+431", "s1"))
+	dryRun := testIngress("default", "b", "default", testIngressRule("a.example.org", "+431", "s2"), testIngressRule("a.example.org", "+432", "s2"))
+	dryRun.Annotations = map[string]string{Name_AnnotationDryRun: "true"}
+
+	ingresses := []kasicov1.Ingress{existing, dryRun}
+	published := GetRoutingData(*router, ingresses, nil)
+	assert.Equal(t, 1, len(published.Rules))
+
+	preview := previewIngress(router, ingresses, nil, published, &ingresses[1])
+	assert.Equal(t, 2, len(preview.Added))
+	assert.Equal(t, 0, len(preview.Removed))
+	// the domain and the headnumber +431 are routed to the existing Ingress
+	assert.Equal(t, 3, len(preview.Shadowed))
+	assert.Contains(t, preview.Shadowed, "a.example.org +432 -> s2.default (default/b) is shadowed by a.example.org +431 -> s1.default (default/a) for the domain a.example.org")
+}
@@ -2,13 +2,20 @@ package controllers
 
 import (
 	"context"
-	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+	"sync"
 	"time"
 
 	"github.com/go-logr/logr"
 	kasicov1 "github.com/world-direct/kasico/operator/api/v1"
 	"github.com/world-direct/kasico/operator/controllers/debounce"
 	corev1 "k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	ctrllog "sigs.k8s.io/controller-runtime/pkg/log"
@@ -18,7 +25,9 @@ import (
 //   * Reading all RouterInstance objects
 //	 * Reading all Ingress objects
 //	 * Map the RoutingData for each RouterInstance
-//	 * Serialize the RoutingData into the routing-data configmap
+//	 * Increment the Generation in the RouterInstance status, if the RoutingData has been changed
+//	 * Keep each generation as an immutable revision ConfigMap
+//	 * Serialize the RoutingData (or the pinned revision) into the routing-data configmap
 //
 //	We use this singleton instead putting this directly into the reconciler
 //	basically to
@@ -28,17 +37,27 @@ import (
 type generator struct {
 	Client client.Client
 	f      func(f func())
+
+	// the changes since the last run, so we can only regenerate the affected RouterInstances
+	mu              sync.Mutex
+	pendingAll      bool
+	pendingClasses  map[string]bool
+	pendingTriggers []string
 }
 
 type Generator interface {
 	Start(ctx context.Context) error
-	OnObjectsChanged(ctx context.Context)
+	OnObjectsChanged(ctx context.Context, trigger string)
+	OnIngressClassesChanged(ctx context.Context, trigger string, ingressClassNames []string)
 }
 
-func NewGenerator(client client.Client, debounceTime time.Duration) Generator {
+// NewGenerator returns a Generator, which generates the routing-data after no changes happened
+// for the debounceTime, but at the latest maxWait after the first change. 0 waits without limit.
+func NewGenerator(client client.Client, debounceTime time.Duration, maxWait time.Duration) Generator {
 	generator := &generator{
-		Client: client,
-		f:      debounce.New(debounceTime),
+		Client:         client,
+		f:              debounce.NewWithMaxWait(debounceTime, maxWait),
+		pendingClasses: map[string]bool{},
 	}
 
 	return generator
@@ -57,19 +76,95 @@ func (r *generator) Start(ctx context.Context) error {
 
 // Call this method whenever something has changed
 // The Generator will debounce calls, and reevaluate the cluster state afterwards
-func (generator *generator) OnObjectsChanged(ctx context.Context) {
+// The trigger describes the change, and is recorded in the routing-data revision
+func (generator *generator) OnObjectsChanged(ctx context.Context, trigger string) {
 	log := ctrllog.FromContext(ctx)
-	log.V(2).Info("Generator notified OnObjectsChanged")
+	log.V(2).Info("Generator notified OnObjectsChanged", "trigger", trigger)
+
+	generator.mu.Lock()
+	generator.pendingAll = true
+	generator.addTrigger(trigger)
+	generator.mu.Unlock()
 
 	generator.f(func() { generator.reconcile(context.Background()) })
 }
 
+// Call this method if something has changed, that only affects the RouterInstances
+// of the given ingressClassNames. Calls are debounced like OnObjectsChanged.
+func (generator *generator) OnIngressClassesChanged(ctx context.Context, trigger string, ingressClassNames []string) {
+	log := ctrllog.FromContext(ctx)
+	log.V(2).Info("Generator notified OnIngressClassesChanged", "trigger", trigger, "ingressClassNames", ingressClassNames)
+
+	if len(ingressClassNames) == 0 {
+		return
+	}
+
+	generator.mu.Lock()
+	for _, name := range ingressClassNames {
+		generator.pendingClasses[name] = true
+	}
+	generator.addTrigger(trigger)
+	generator.mu.Unlock()
+
+	generator.f(func() { generator.reconcile(context.Background()) })
+}
+
+// pendingChanges are the changes collected since the last run
+type pendingChanges struct {
+	all      bool
+	classes  map[string]bool
+	triggers []string
+}
+
+func (pending *pendingChanges) affects(router *kasicov1.RouterInstance) bool {
+	return pending.affectsClass(router.Spec.IngressClassName)
+}
+
+func (pending *pendingChanges) affectsClass(ingressClassName string) bool {
+	return pending.all || pending.classes[ingressClassName]
+}
+
+// addTrigger records what caused the change, we keep only a limited number
+// because they are stored in an annotation of the revision
+func (generator *generator) addTrigger(trigger string) {
+	const maxTriggers = 10
+
+	for _, existing := range generator.pendingTriggers {
+		if existing == trigger {
+			return
+		}
+	}
+
+	if len(generator.pendingTriggers) < maxTriggers {
+		generator.pendingTriggers = append(generator.pendingTriggers, trigger)
+	}
+}
+
+// takePending returns the changes since the last run, and resets them
+func (generator *generator) takePending() *pendingChanges {
+	generator.mu.Lock()
+	defer generator.mu.Unlock()
+
+	pending := &pendingChanges{
+		all:      generator.pendingAll,
+		classes:  generator.pendingClasses,
+		triggers: generator.pendingTriggers,
+	}
+
+	generator.pendingAll = false
+	generator.pendingClasses = map[string]bool{}
+	generator.pendingTriggers = nil
+
+	return pending
+}
+
 func (generator *generator) reconcile(ctx context.Context) {
 	log := ctrllog.FromContext(ctx)
+	pending := generator.takePending()
 
 	for retry := 0; retry < 5; retry++ {
 		log.Info("Running Generator", "retry", retry)
-		err := generator.reconcileImpl(ctx, log)
+		err := generator.reconcileImpl(ctx, log, pending)
 		if err == nil {
 			log.Info("Generator finished")
 			return
@@ -78,9 +173,27 @@ func (generator *generator) reconcile(ctx context.Context) {
 		log.Error(err, "Error running Generator")
 	}
 
+	// the changes are not lost, but regenerated with the next run
+	log.Info("Generator failed, the changes are requeued")
+	generator.requeuePending(pending)
+	generator.f(func() { generator.reconcile(context.Background()) })
 }
 
-func (generator *generator) reconcileImpl(ctx context.Context, log logr.Logger) error {
+// requeuePending adds the changes of a failed run to the changes since then
+func (generator *generator) requeuePending(pending *pendingChanges) {
+	generator.mu.Lock()
+	defer generator.mu.Unlock()
+
+	generator.pendingAll = generator.pendingAll || pending.all
+	for name := range pending.classes {
+		generator.pendingClasses[name] = true
+	}
+	for _, trigger := range pending.triggers {
+		generator.addTrigger(trigger)
+	}
+}
+
+func (generator *generator) reconcileImpl(ctx context.Context, log logr.Logger, pending *pendingChanges) error {
 
 	var err error
 	routers := &kasicov1.RouterInstanceList{}
@@ -95,47 +208,191 @@ func (generator *generator) reconcileImpl(ctx context.Context, log logr.Logger)
 		return err
 	}
 
+	backends := &Backends{}
+	services := &corev1.ServiceList{}
+	err = generator.Client.List(ctx, services)
+	if err != nil {
+		return err
+	}
+	backends.Services = services.Items
+
 	for _, router := range routers.Items {
-		routingData := GetRoutingData(router, ingresses.Items)
+		if router.Spec.ResolveEndpoints {
+			endpointSlices := &discoveryv1.EndpointSliceList{}
+			err = generator.Client.List(ctx, endpointSlices)
+			if err != nil {
+				return err
+			}
+			backends.EndpointSlices = endpointSlices.Items
+			break
+		}
+	}
 
-		log = log.WithValues("ingressClassName", router.Spec.IngressClassName)
+	previews := map[string][]kasicov1.IngressPreview{}
+	for _, router := range routers.Items {
+		if !pending.affects(&router) {
+			continue
+		}
 
-		routerDataJsonBytes, err := json.MarshalIndent(routingData, "", "  ")
+		routerPreviews, err := generator.reconcileRouter(ctx, log.WithValues("ingressClassName", router.Spec.IngressClassName),
+			router, ingresses.Items, backends, pending.triggers)
 		if err != nil {
 			return err
 		}
 
-		routerDataMap := make(map[string]string)
-		routerDataMap[Name_RouningDataJson] = string(routerDataJsonBytes)
-		routerDataHash := HashStringMap(routerDataMap)
+		for owner, preview := range routerPreviews {
+			previews[owner] = append(previews[owner], preview)
+		}
+	}
+
+	return generator.updatePreviews(ctx, log, ingresses.Items, previews, pending)
 
-		cmRoutingData := &corev1.ConfigMap{}
-		err = generator.Client.Get(ctx, types.NamespacedName{Name: Name_ConfigMap, Namespace: router.Namespace}, cmRoutingData)
-		if err != nil {
-			return err
+}
+
+// reconcileRouter publishes the routing-data of the router, and returns the
+// previews of the dry-run Ingresses by owner
+func (generator *generator) reconcileRouter(ctx context.Context, log logr.Logger, router kasicov1.RouterInstance, ingresses []kasicov1.Ingress, backends *Backends, triggers []string) (map[string]kasicov1.IngressPreview, error) {
+
+	cmRoutingData := &corev1.ConfigMap{}
+	err := generator.Client.Get(ctx, types.NamespacedName{Name: Name_ConfigMap, Namespace: router.Namespace}, cmRoutingData)
+	if err != nil {
+		return nil, err
+	}
+
+	// the currently published data is needed to keep the rules of dry-run Ingresses
+	published, err := UnmarshalRoutingData(cmRoutingData.Data[Name_RouningDataJson])
+	if err != nil {
+		published = nil
+	}
+
+	routingData := ProposedRoutingData(router, ingresses, backends, published)
+
+	previews := map[string]kasicov1.IngressPreview{}
+	for i := range ingresses {
+		ingress := &ingresses[i]
+		if ingress.Spec.IngressClassName == router.Spec.IngressClassName && IsDryRun(ingress) {
+			previews[ingress.Namespace+"/"+ingress.Name] = previewIngress(&router, ingresses, backends, published, ingress)
 		}
+	}
 
-		existingHash := GetAnnotation(&cmRoutingData.ObjectMeta, Name_AnnotationRoutingDataHash)
+	routerDataHash, err := HashRoutingData(routingData)
+	if err != nil {
+		return nil, err
+	}
 
-		// check if the routerdata has been changed
-		if routerDataHash != existingHash {
-			log.Info("The hash of the data been changed, updating " + Name_ConfigMap)
+	// the generation is only incremented for effective changes, so we track
+	// it together with the hash in the status of the RouterInstance
+	status := router.Status.DeepCopy()
+	if routerDataHash != router.Status.RouterDataHash {
+		routingData.Generation++
+		log.Info("The routing-data has been changed, incrementing generation", "generation", routingData.Generation)
 
-			cmRoutingData.Data = routerDataMap
-			SetAnnotation(&cmRoutingData.ObjectMeta, Name_AnnotationRoutingDataHash, routerDataHash)
-			err = generator.Client.Update(ctx, cmRoutingData)
+		status.RouterDataHash = routerDataHash
+		status.RoutingDataGeneration = routingData.Generation
+	}
 
-			if err != nil {
-				log.Error(err, "Unable to update the routing-data configmap!")
-				return err
-			}
+	routerDataJson, err := MarshalRoutingData(routingData)
+	if err != nil {
+		return nil, err
+	}
+
+	routerDataMap := make(map[string]string)
+	routerDataMap[Name_RouningDataJson] = routerDataJson
+	routerDataGeneration := strconv.Itoa(routingData.Generation)
+
+	// every generation is kept as an immutable revision, so we can roll back to it
+	revision, err := generator.ensureRevision(ctx, &router, routerDataMap, routerDataHash, routerDataGeneration, triggers)
+	if err != nil {
+		log.Error(err, "Unable to create the routing-data revision!")
+		return nil, err
+	}
+
+	if status.Conditions == nil {
+		status.Conditions = []metav1.Condition{}
+	}
+
+	// if the RouterInstance is pinned, we publish the pinned revision instead
+	publish := true
+	if router.Spec.PinnedRevision != "" {
+		pinned, err := generator.getPinnedRevision(ctx, &router)
+		switch {
+		case errors.IsNotFound(err):
+			log.Info("The pinned revision doesn't exist, nothing is published", "revision", router.Spec.PinnedRevision)
+			meta.SetStatusCondition(&status.Conditions, metav1.Condition{
+				Type:    Condition_RevisionPinned,
+				Status:  metav1.ConditionFalse,
+				Reason:  "PinnedRevisionNotFound",
+				Message: fmt.Sprintf("the pinned revision %s doesn't exist, the revision %s remains published", router.Spec.PinnedRevision, router.Status.CurrentRevision),
+			})
+			publish = false
 
-			log.Info("Successfully updated the ConfigMap")
-		} else {
-			log.Info("Nothing has changed")
+		case err != nil:
+			log.Error(err, "Unable to get the pinned revision!", "revision", router.Spec.PinnedRevision)
+			return nil, err
+
+		default:
+			log.Info("The RouterInstance is pinned to a revision", "revision", pinned.Name)
+			meta.SetStatusCondition(&status.Conditions, metav1.Condition{
+				Type:    Condition_RevisionPinned,
+				Status:  metav1.ConditionTrue,
+				Reason:  "Pinned",
+				Message: fmt.Sprintf("the pinned revision %s is published", pinned.Name),
+			})
+
+			revision = pinned
+			routerDataMap = revision.Data
+			routerDataHash = GetAnnotation(&revision.ObjectMeta, Name_AnnotationRoutingDataHash)
+			routerDataGeneration = GetAnnotation(&revision.ObjectMeta, Name_AnnotationRoutingDataGeneration)
 		}
+	} else {
+		meta.RemoveStatusCondition(&status.Conditions, Condition_RevisionPinned)
 	}
 
-	return nil
+	if publish {
+		status.CurrentRevision = revision.Name
+	}
+
+	if !reflect.DeepEqual(status, &router.Status) {
+		router.Status = *status
+		err = generator.Client.Status().Update(ctx, &router)
+		if err != nil {
+			log.Error(err, "Unable to update the status of the RouterInstance!")
+			return nil, err
+		}
+	}
+
+	err = generator.pruneRevisions(ctx, &router)
+	if err != nil {
+		log.Error(err, "Unable to prune the routing-data revisions!")
+		return nil, err
+	}
+
+	if !publish {
+		return previews, nil
+	}
+
+	existingHash := GetAnnotation(&cmRoutingData.ObjectMeta, Name_AnnotationRoutingDataHash)
+	existingGeneration := GetAnnotation(&cmRoutingData.ObjectMeta, Name_AnnotationRoutingDataGeneration)
+
+	// check if the routerdata has been changed
+	if routerDataHash != existingHash || routerDataGeneration != existingGeneration {
+		log.Info("The hash of the data been changed, updating "+Name_ConfigMap, "revision", revision.Name)
+
+		cmRoutingData.Data = routerDataMap
+		SetAnnotation(&cmRoutingData.ObjectMeta, Name_AnnotationRoutingDataHash, routerDataHash)
+		SetAnnotation(&cmRoutingData.ObjectMeta, Name_AnnotationRoutingDataGeneration, routerDataGeneration)
+		SetAnnotation(&cmRoutingData.ObjectMeta, Name_AnnotationRoutingRevision, revision.Name)
+		err = generator.Client.Update(ctx, cmRoutingData)
+
+		if err != nil {
+			log.Error(err, "Unable to update the routing-data configmap!")
+			return nil, err
+		}
+
+		log.Info("Successfully updated the ConfigMap")
+	} else {
+		log.Info("Nothing has changed")
+	}
 
+	return previews, nil
 }
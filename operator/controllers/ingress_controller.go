@@ -53,10 +53,6 @@ func (r *IngressReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ct
 	log := ctrllog.FromContext(ctx)
 	log.V(2).Info("Reconcile Ingress")
 
-	// we notify the generator, because it implements debouncing, and implements the logic
-	// to check if an update is really needed.
-	r.Generator.OnObjectsChanged(ctx)
-
 	// Fetch the Ingress
 	ingress := &kasicov1.Ingress{}
 	err := r.Get(ctx, req.NamespacedName, ingress)
@@ -66,6 +62,7 @@ func (r *IngressReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ct
 			// Owned objects are automatically garbage collected. For additional cleanup logic use finalizers.
 			// Return and don't requeue
 			log.Info("Ingress resource not found. Ignoring since object must be deleted.")
+			r.Generator.OnObjectsChanged(ctx, DescribeDeletion("Ingress", req.NamespacedName))
 
 			return ctrl.Result{}, nil
 		}
@@ -75,6 +72,10 @@ func (r *IngressReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ct
 		return ctrl.Result{}, err
 	}
 
+	// we notify the generator, because it implements debouncing, and implements the logic
+	// to check if an update is really needed.
+	r.Generator.OnObjectsChanged(ctx, DescribeTrigger("Ingress", ingress))
+
 	return ctrl.Result{}, nil
 }
 
@@ -0,0 +1,148 @@
+package controllers
+
+import (
+	"context"
+	"reflect"
+	"sort"
+
+	"github.com/go-logr/logr"
+	kasicov1 "github.com/world-direct/kasico/operator/api/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Ingresses with the Name_AnnotationDryRun annotation are not published.
+// Instead, the generator keeps publishing the rules of the Ingress from the
+// current routing-data, and computes a preview of the changes for the Ingress status.
+
+// keepDryRunRules adds the published rules of all dry-run Ingresses (except the
+// Ingress named except) to the RoutingData, so they are not removed while previewing.
+func keepDryRunRules(rd *RoutingData, published *RoutingData, router *kasicov1.RouterInstance, ingresses []kasicov1.Ingress, except string) {
+	if published == nil {
+		return
+	}
+
+	owners := map[string]bool{}
+	for _, ingress := range ingresses {
+		owner := ingress.Namespace + "/" + ingress.Name
+		if ingress.Spec.IngressClassName == router.Spec.IngressClassName && IsDryRun(&ingress) && owner != except {
+			owners[owner] = true
+		}
+	}
+
+	if len(owners) == 0 {
+		return
+	}
+
+	for _, rule := range published.Rules {
+		if owners[rule.Owner] {
+			rd.Rules = append(rd.Rules, rule)
+		}
+	}
+
+	SortRoutingRules(rd.Rules)
+}
+
+// ProposedRoutingData returns the routing-data the generator publishes for the Ingresses,
+// including the published rules of the dry-run Ingresses
+func ProposedRoutingData(router kasicov1.RouterInstance, ingresses []kasicov1.Ingress, backends *Backends, published *RoutingData) *RoutingData {
+	rd := GetRoutingData(router, ingresses, backends)
+	keepDryRunRules(rd, published, &router, ingresses, "")
+
+	return rd
+}
+
+// previewIngress computes the changes to the published RoutingData, if the dry-run annotation
+// would be removed from the Ingress
+func previewIngress(router *kasicov1.RouterInstance, ingresses []kasicov1.Ingress, backends *Backends, published *RoutingData, ingress *kasicov1.Ingress) kasicov1.IngressPreview {
+	owner := ingress.Namespace + "/" + ingress.Name
+
+	applied := make([]kasicov1.Ingress, len(ingresses))
+	for i := range ingresses {
+		applied[i] = ingresses[i]
+		if ingresses[i].Namespace == ingress.Namespace && ingresses[i].Name == ingress.Name {
+			applied[i] = *ingress.DeepCopy()
+			delete(applied[i].Annotations, Name_AnnotationDryRun)
+		}
+	}
+
+	candidate := GetRoutingData(*router, applied, backends)
+	keepDryRunRules(candidate, published, router, ingresses, owner)
+
+	preview := kasicov1.IngressPreview{
+		RouterInstance: router.Namespace + "/" + router.Name,
+	}
+
+	publishedRules := map[string]bool{}
+	if published != nil {
+		for _, rule := range published.Rules {
+			if rule.Owner == owner {
+				publishedRules[rule.String()] = true
+			}
+		}
+	}
+
+	candidateRules := map[string]bool{}
+	for _, rule := range candidate.Rules {
+		if rule.Owner == owner {
+			candidateRules[rule.String()] = true
+			if !publishedRules[rule.String()] {
+				preview.Added = append(preview.Added, rule.String())
+			}
+		}
+	}
+
+	for rule := range publishedRules {
+		if !candidateRules[rule] {
+			preview.Removed = append(preview.Removed, rule)
+		}
+	}
+	sort.Strings(preview.Removed)
+
+	// like in the routers, see MatchRoute
+	_, shadowed := ResolveRoutes(candidate.Rules)
+	for _, s := range shadowed {
+		if s.Rule.Owner == owner || s.By.Owner == owner {
+			preview.Shadowed = append(preview.Shadowed, s.Rule.String()+" is shadowed by "+s.By.String()+" for the "+s.MatchType+" "+s.Key)
+		}
+	}
+
+	return preview
+}
+
+// updatePreviews writes the previews into the status of the Ingresses of the affected RouterInstances.
+// The preview of Ingresses without the dry-run annotation is removed.
+func (generator *generator) updatePreviews(ctx context.Context, log logr.Logger, ingresses []kasicov1.Ingress, previews map[string][]kasicov1.IngressPreview, pending *pendingChanges) error {
+	for _, ingress := range ingresses {
+		if !pending.affectsClass(ingress.Spec.IngressClassName) {
+			continue
+		}
+
+		preview := previews[ingress.Namespace+"/"+ingress.Name]
+		sort.Slice(preview, func(i, j int) bool {
+			return preview[i].RouterInstance < preview[j].RouterInstance
+		})
+
+		if len(preview) == 0 && len(ingress.Status.Preview) == 0 {
+			continue
+		}
+
+		if reflect.DeepEqual(preview, ingress.Status.Preview) {
+			continue
+		}
+
+		log.Info("Updating the preview of the Ingress", "ingress", ingress.Namespace+"/"+ingress.Name)
+
+		ingress.Status.Preview = preview
+		if ingress.Status.Conditions == nil {
+			ingress.Status.Conditions = []metav1.Condition{}
+		}
+
+		err := generator.Client.Status().Update(ctx, &ingress)
+		if err != nil {
+			log.Error(err, "Unable to update the preview of the Ingress!")
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,151 @@
+package controllers
+
+import (
+	"context"
+	"sort"
+	"strconv"
+	"strings"
+
+	kasicov1 "github.com/world-direct/kasico/operator/api/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// Each generation of the routing-data is stored in an immutable ConfigMap, named
+// after the hash of its content. The last RevisionHistoryLimit revisions are kept,
+// so a RouterInstance can be pinned to one of them to roll back a bad change.
+
+//+kubebuilder:rbac:groups=core,resources=configmaps,verbs=get;list;watch;create;update;patch;delete
+
+const defaultRevisionHistoryLimit = 10
+
+// RevisionName returns the name of the revision ConfigMap for the data of the router.
+// The name contains the router, because the routers of a namespace may publish the same data.
+func RevisionName(router *kasicov1.RouterInstance, data map[string]string) string {
+	hash := strings.TrimPrefix(HashStringMap(data), "md5:")
+	return Name_ConfigMap + "-" + router.Name + "-" + hash[:10]
+}
+
+// ensureRevision creates the revision ConfigMap for the data, if it doesn't exist yet
+func (generator *generator) ensureRevision(ctx context.Context, router *kasicov1.RouterInstance, data map[string]string, hash string, generation string, triggers []string) (*corev1.ConfigMap, error) {
+
+	name := RevisionName(router, data)
+	revision, err := generator.getRevision(ctx, router, name)
+	if err == nil {
+		return revision, nil
+	}
+
+	if !errors.IsNotFound(err) {
+		return nil, err
+	}
+
+	immutable := true
+	revision = &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: router.Namespace,
+		},
+		Immutable: &immutable,
+		Data:      data,
+	}
+
+	SetLabel(&revision.ObjectMeta, Name_LabelRoutingRevision, router.Name)
+	SetAnnotation(&revision.ObjectMeta, Name_AnnotationRoutingDataHash, hash)
+	SetAnnotation(&revision.ObjectMeta, Name_AnnotationRoutingDataGeneration, generation)
+	SetAnnotation(&revision.ObjectMeta, Name_AnnotationRoutingTriggeredBy, strings.Join(triggers, "\n"))
+
+	// the revisions are garbage collected together with the RouterInstance
+	err = ctrl.SetControllerReference(router, revision, generator.Client.Scheme())
+	if err != nil {
+		return nil, err
+	}
+
+	// the cache may not have seen a revision we created in the last run
+	err = generator.Client.Create(ctx, revision)
+	if err != nil && !errors.IsAlreadyExists(err) {
+		return nil, err
+	}
+
+	return revision, nil
+}
+
+// getRevision returns the revision ConfigMap with the given name
+func (generator *generator) getRevision(ctx context.Context, router *kasicov1.RouterInstance, name string) (*corev1.ConfigMap, error) {
+	revision := &corev1.ConfigMap{}
+	err := generator.Client.Get(ctx, types.NamespacedName{Name: name, Namespace: router.Namespace}, revision)
+	if err != nil {
+		return nil, err
+	}
+
+	return revision, nil
+}
+
+// getPinnedRevision returns the revision the router is pinned to. A ConfigMap which
+// isn't a revision of the router is reported as not found.
+func (generator *generator) getPinnedRevision(ctx context.Context, router *kasicov1.RouterInstance) (*corev1.ConfigMap, error) {
+	revision, err := generator.getRevision(ctx, router, router.Spec.PinnedRevision)
+	if err != nil {
+		return nil, err
+	}
+
+	if GetLabel(&revision.ObjectMeta, Name_LabelRoutingRevision) != router.Name {
+		return nil, errors.NewNotFound(corev1.Resource("configmaps"), router.Spec.PinnedRevision)
+	}
+
+	return revision, nil
+}
+
+// ListRevisions returns the revision ConfigMaps of the RouterInstance, newest first
+func ListRevisions(ctx context.Context, c client.Reader, router *kasicov1.RouterInstance) ([]corev1.ConfigMap, error) {
+	revisions := &corev1.ConfigMapList{}
+	err := c.List(ctx, revisions,
+		client.InNamespace(router.Namespace),
+		client.MatchingLabels{Name_LabelRoutingRevision: router.Name})
+	if err != nil {
+		return nil, err
+	}
+
+	items := revisions.Items
+	sort.SliceStable(items, func(i, j int) bool {
+		return revisionGeneration(&items[i]) > revisionGeneration(&items[j])
+	})
+
+	return items, nil
+}
+
+// pruneRevisions deletes the oldest revisions exceeding the RevisionHistoryLimit.
+// The current and the pinned revision are always kept.
+func (generator *generator) pruneRevisions(ctx context.Context, router *kasicov1.RouterInstance) error {
+	limit := router.Spec.RevisionHistoryLimit
+	if limit <= 0 {
+		limit = defaultRevisionHistoryLimit
+	}
+
+	revisions, err := ListRevisions(ctx, generator.Client, router)
+	if err != nil {
+		return err
+	}
+
+	for i := range revisions {
+		revision := &revisions[i]
+		if i < limit || revision.Name == router.Status.CurrentRevision || revision.Name == router.Spec.PinnedRevision {
+			continue
+		}
+
+		err = generator.Client.Delete(ctx, revision)
+		if err != nil && !errors.IsNotFound(err) {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func revisionGeneration(revision *corev1.ConfigMap) int {
+	generation, _ := strconv.Atoi(GetAnnotation(&revision.ObjectMeta, Name_AnnotationRoutingDataGeneration))
+	return generation
+}
@@ -0,0 +1,276 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	ctrllog "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+
+	kasicov1 "github.com/world-direct/kasico/operator/api/v1"
+)
+
+// RolloutReconciler aggregates the routing-data applied by the router pods, as reported
+// by the sidecars in the pod annotations, into the status of the RouterInstance.
+type RolloutReconciler struct {
+	client.Client
+	Scheme *runtime.Scheme
+}
+
+//+kubebuilder:rbac:groups=core,resources=pods,verbs=get;list;watch
+//+kubebuilder:rbac:groups=kasico.world-direct.at,resources=routerinstances/status,verbs=get;update;patch
+
+func (r *RolloutReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+
+	log := ctrllog.FromContext(ctx)
+	log.V(2).Info("Reconcile Rollout")
+
+	router := &kasicov1.RouterInstance{}
+	err := r.Get(ctx, req.NamespacedName, router)
+	if err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+
+	// the sidecars report the hash of the routing-data they applied, which is compared
+	// to the published one, and not to the latest, which may be pinned
+	publishedHash := ""
+	publishedGeneration := 0
+	cmRoutingData := &corev1.ConfigMap{}
+	err = r.Get(ctx, types.NamespacedName{Name: Name_ConfigMap, Namespace: router.Namespace}, cmRoutingData)
+	if err == nil {
+		publishedHash = GetAnnotation(&cmRoutingData.ObjectMeta, Name_AnnotationRoutingDataHash)
+		publishedGeneration, _ = strconv.Atoi(GetAnnotation(&cmRoutingData.ObjectMeta, Name_AnnotationRoutingDataGeneration))
+	} else if !errors.IsNotFound(err) {
+		return ctrl.Result{}, err
+	}
+
+	pods := &corev1.PodList{}
+	err = r.List(ctx, pods, client.InNamespace(router.Namespace), client.MatchingLabels(routerPodLabels(router)))
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+
+	status := router.Status.DeepCopy()
+	rollout := AggregateRollout(pods.Items, publishedHash)
+	status.ReadyPods = rollout.ReadyPods
+	status.UpdatedPods = rollout.UpdatedPods
+	status.AppliedRoutingData = rollout.Applied
+
+	if status.Conditions == nil {
+		status.Conditions = []metav1.Condition{}
+	}
+	meta.SetStatusCondition(&status.Conditions, rollout.Condition(publishedGeneration))
+	meta.SetStatusCondition(&status.Conditions, rollout.ValidCondition())
+
+	if reflect.DeepEqual(status, &router.Status) {
+		return ctrl.Result{}, nil
+	}
+
+	router.Status = *status
+	err = r.Status().Update(ctx, router)
+	if err != nil {
+		if errors.IsConflict(err) {
+			return ctrl.Result{Requeue: true}, nil
+		}
+		return ctrl.Result{}, err
+	}
+
+	log.Info("Rollout status updated", "pods", rollout.Pods, "updatedPods", rollout.UpdatedPods, "readyPods", rollout.ReadyPods)
+	return ctrl.Result{}, nil
+}
+
+// Rollout is the aggregated state of the router pods
+type Rollout struct {
+	Pods        int
+	ReadyPods   int
+	UpdatedPods int
+	Applied     []kasicov1.AppliedRoutingData
+
+	// Rejected are the errors reported by the pods which rejected their configuration, sorted by pod
+	Rejected []string
+}
+
+// AggregateRollout counts the pods by the routing-data they applied.
+// Pods without the annotation are counted with an empty hash.
+func AggregateRollout(pods []corev1.Pod, publishedHash string) *Rollout {
+	rollout := &Rollout{}
+	byHash := map[string]*kasicov1.AppliedRoutingData{}
+
+	for i := range pods {
+		pod := &pods[i]
+		if pod.DeletionTimestamp != nil {
+			continue
+		}
+
+		hash := GetAnnotation(&pod.ObjectMeta, Name_AnnotationAppliedHash)
+		generation, _ := strconv.Atoi(GetAnnotation(&pod.ObjectMeta, Name_AnnotationAppliedGeneration))
+
+		applied := byHash[hash]
+		if applied == nil {
+			applied = &kasicov1.AppliedRoutingData{Hash: hash, Generation: generation}
+			byHash[hash] = applied
+		}
+
+		rollout.Pods++
+		applied.Pods++
+
+		ready := isPodReady(pod)
+		if ready {
+			rollout.ReadyPods++
+			applied.ReadyPods++
+		}
+
+		if hash != "" && hash == publishedHash {
+			rollout.UpdatedPods++
+		}
+
+		if configError := GetAnnotation(&pod.ObjectMeta, Name_AnnotationConfigError); configError != "" {
+			rollout.Rejected = append(rollout.Rejected, pod.Name+": "+configError)
+		}
+	}
+
+	sort.Strings(rollout.Rejected)
+
+	for _, applied := range byHash {
+		rollout.Applied = append(rollout.Applied, *applied)
+	}
+
+	// the latest generation first
+	sort.Slice(rollout.Applied, func(i, j int) bool {
+		if rollout.Applied[i].Generation != rollout.Applied[j].Generation {
+			return rollout.Applied[i].Generation > rollout.Applied[j].Generation
+		}
+		return rollout.Applied[i].Hash < rollout.Applied[j].Hash
+	})
+
+	return rollout
+}
+
+// Condition returns the ConfigApplied condition for the rollout
+func (rollout *Rollout) Condition(publishedGeneration int) metav1.Condition {
+	condition := metav1.Condition{
+		Type:    Condition_ConfigApplied,
+		Status:  metav1.ConditionFalse,
+		Reason:  "RolloutInProgress",
+		Message: fmt.Sprintf("%d/%d router pods applied the routing-data generation %d", rollout.UpdatedPods, rollout.Pods, publishedGeneration),
+	}
+
+	if rollout.Pods == 0 {
+		condition.Reason = "NoPods"
+		condition.Message = "there are no router pods"
+	} else if rollout.UpdatedPods == rollout.Pods {
+		condition.Status = metav1.ConditionTrue
+		condition.Reason = "AllPodsUpdated"
+	}
+
+	return condition
+}
+
+// ValidCondition returns the ConfigValid condition, which is false if a pod rejected its configuration
+func (rollout *Rollout) ValidCondition() metav1.Condition {
+	condition := metav1.Condition{
+		Type:    Condition_ConfigValid,
+		Status:  metav1.ConditionTrue,
+		Reason:  "NoPodRejected",
+		Message: fmt.Sprintf("none of the %d router pods rejected the configuration", rollout.Pods),
+	}
+
+	if rollout.Pods == 0 {
+		condition.Status = metav1.ConditionUnknown
+		condition.Reason = "NoPods"
+		condition.Message = "there are no router pods"
+	} else if len(rollout.Rejected) > 0 {
+		condition.Status = metav1.ConditionFalse
+		condition.Reason = "ConfigRejected"
+		condition.Message = fmt.Sprintf("%d/%d router pods rejected the configuration: %s", len(rollout.Rejected), rollout.Pods, strings.Join(rollout.Rejected, "; "))
+	}
+
+	return condition
+}
+
+func isPodReady(pod *corev1.Pod) bool {
+	for _, condition := range pod.Status.Conditions {
+		if condition.Type == corev1.PodReady {
+			return condition.Status == corev1.ConditionTrue
+		}
+	}
+
+	return false
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *RolloutReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	// only the events of the router pods are mapped
+	routerPods, err := predicate.LabelSelectorPredicate(metav1.LabelSelector{MatchLabels: routerPodLabels(nil)})
+	if err != nil {
+		return err
+	}
+
+	return ctrl.NewControllerManagedBy(mgr).
+		Named("rollout").
+		For(&kasicov1.RouterInstance{}).
+		Owns(&corev1.ConfigMap{}).
+		Watches(&source.Kind{Type: &corev1.Pod{}},
+			handler.EnqueueRequestsFromMapFunc(r.podToRouterInstances),
+			builder.WithPredicates(routerPods)).
+		Complete(r)
+}
+
+// podMappingTimeout bounds the listing of the RouterInstances of a pod event
+const podMappingTimeout = 10 * time.Second
+
+// podToRouterInstances maps a router pod to the RouterInstances in its namespace.
+// The MapFunc of this controller-runtime version gets no context, so the listing is bounded by a timeout.
+func (r *RolloutReconciler) podToRouterInstances(obj client.Object) []reconcile.Request {
+	ctx, cancel := context.WithTimeout(context.Background(), podMappingTimeout)
+	defer cancel()
+
+	routers := &kasicov1.RouterInstanceList{}
+	err := r.List(ctx, routers, client.InNamespace(obj.GetNamespace()))
+	if err != nil {
+		ctrllog.FromContext(ctx).Error(err, "Unable to list the RouterInstances of the pod", "pod", obj.GetNamespace()+"/"+obj.GetName())
+		return nil
+	}
+
+	requests := []reconcile.Request{}
+	for _, router := range routers.Items {
+		requests = append(requests, reconcile.Request{
+			NamespacedName: types.NamespacedName{Namespace: router.Namespace, Name: router.Name},
+		})
+	}
+
+	return requests
+}
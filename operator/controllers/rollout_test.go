@@ -0,0 +1,66 @@
+package controllers
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func testPod(hash string, generation string, ready bool) corev1.Pod {
+	pod := corev1.Pod{}
+	if hash != "" {
+		SetAnnotation(&pod.ObjectMeta, Name_AnnotationAppliedHash, hash)
+		SetAnnotation(&pod.ObjectMeta, Name_AnnotationAppliedGeneration, generation)
+	}
+
+	status := corev1.ConditionFalse
+	if ready {
+		status = corev1.ConditionTrue
+	}
+	pod.Status.Conditions = []corev1.PodCondition{{Type: corev1.PodReady, Status: status}}
+	return pod
+}
+
+func TestAggregateRollout(t *testing.T) {
+	rollout := AggregateRollout([]corev1.Pod{
+		testPod("md5:new", "2", true),
+		testPod("md5:new", "2", false),
+		testPod("md5:old", "1", true),
+		testPod("", "", false),
+	}, "md5:new")
+
+	assert.Equal(t, 4, rollout.Pods)
+	assert.Equal(t, 2, rollout.ReadyPods)
+	assert.Equal(t, 2, rollout.UpdatedPods)
+	assert.Equal(t, 3, len(rollout.Applied))
+	assert.Equal(t, "md5:new", rollout.Applied[0].Hash)
+	assert.Equal(t, 2, rollout.Applied[0].Pods)
+	assert.Equal(t, 1, rollout.Applied[0].ReadyPods)
+
+	condition := rollout.Condition(2)
+	assert.Equal(t, metav1.ConditionFalse, condition.Status)
+	assert.Equal(t, "2/4 router pods applied the routing-data generation 2", condition.Message)
+
+	rollout = AggregateRollout([]corev1.Pod{testPod("md5:new", "2", true)}, "md5:new")
+	assert.Equal(t, metav1.ConditionTrue, rollout.Condition(2).Status)
+	assert.Equal(t, metav1.ConditionTrue, rollout.ValidCondition().Status)
+}
+
+func TestAggregateRollout_Rejected(t *testing.T) {
+	rejected := testPod("md5:old", "1", true)
+	rejected.Name = "router-b"
+	SetAnnotation(&rejected.ObjectMeta, Name_AnnotationConfigError, "ValidationFailed: generation 2 rejected: syntax error")
+	applied := testPod("md5:new", "2", true)
+	applied.Name = "router-a"
+
+	// a pod applying the configuration doesn't hide the pod rejecting it, regardless of the order
+	rollout := AggregateRollout([]corev1.Pod{rejected, applied}, "md5:new")
+	condition := rollout.ValidCondition()
+	assert.Equal(t, metav1.ConditionFalse, condition.Status)
+	assert.Equal(t, "ConfigRejected", condition.Reason)
+	assert.Equal(t, "1/2 router pods rejected the configuration: router-b: ValidationFailed: generation 2 rejected: syntax error", condition.Message)
+
+	assert.Equal(t, metav1.ConditionUnknown, AggregateRollout(nil, "md5:new").ValidCondition().Status)
+}
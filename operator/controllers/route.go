@@ -0,0 +1,159 @@
+package controllers
+
+import (
+	"fmt"
+	"net"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// The routers select the rule of a request with these semantics, which are implemented
+// by the sample templates:
+//   - a rule with a domain matches all requests to this domain, the host of the Request-URI.
+//     A domain match wins over any headnumber.
+//   - otherwise a rule with a headnumber matches the numbers starting with it, the user of
+//     the To URI. The rule with the longest headnumber wins, the strings are compared as they are.
+//   - rules with a domain and a headnumber match by either of them
+//   - of the rules with the same domain, or the same headnumber, the first in the order of
+//     the routing-data wins, see ResolveRoutes
+//
+// RouteRequest are the parts of a SIP request used for routing
+type RouteRequest struct {
+	Domain string
+	Number string
+}
+
+// NewRouteRequest takes the domain from the Request-URI, and the number from the user of
+// the To URI, like the routers do. Without To URI, the Request-URI is used.
+func NewRouteRequest(requestURI string, to string) (RouteRequest, error) {
+	request := RouteRequest{}
+
+	_, host, err := ParseSIPURI(requestURI)
+	if err != nil {
+		return request, err
+	}
+	request.Domain = host
+
+	if to == "" {
+		to = requestURI
+	}
+
+	request.Number, _, err = ParseSIPURI(to)
+	if err != nil {
+		return request, err
+	}
+
+	return request, nil
+}
+
+// ParseSIPURI returns the user and host of a SIP URI, like 'sip:+43512@example.com;transport=udp',
+// '"Alice" <sips:alice@example.com:5061>' or 'tel:+43512'
+func ParseSIPURI(uri string) (string, string, error) {
+	uri = strings.TrimSpace(uri)
+	if start := strings.Index(uri, "<"); start >= 0 {
+		end := strings.Index(uri[start:], ">")
+		if end < 0 {
+			return "", "", fmt.Errorf("invalid uri '%s'", uri)
+		}
+		uri = uri[start+1 : start+end]
+	}
+
+	scheme, rest, found := strings.Cut(uri, ":")
+	if !found {
+		return "", "", fmt.Errorf("invalid uri '%s', no scheme", uri)
+	}
+
+	rest, _, _ = strings.Cut(rest, ";")
+	rest, _, _ = strings.Cut(rest, "?")
+
+	switch strings.ToLower(scheme) {
+	case "tel":
+		return rest, "", nil
+	case "sip", "sips":
+	default:
+		return "", "", fmt.Errorf("invalid uri '%s', unsupported scheme %s", uri, scheme)
+	}
+
+	user, host, found := strings.Cut(rest, "@")
+	if !found {
+		user, host = "", rest
+	}
+
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	host = strings.Trim(host, "[]")
+
+	if host == "" {
+		return "", "", fmt.Errorf("invalid uri '%s', no host", uri)
+	}
+
+	return user, strings.ToLower(host), nil
+}
+
+// RouteMatch is the rule selected for a request
+type RouteMatch struct {
+	Rule RoutingRule
+
+	// MatchType is "domain" or "headnumber"
+	MatchType string
+
+	// Shadowed are the other rules with the same domain or headnumber, which lose against the rule
+	Shadowed []RoutingRule
+
+	// Candidates are the other matching rules, which are less specific
+	Candidates []RoutingRule
+}
+
+// HeadnumberMatches returns true if the number starts with the headnumber. Like in the
+// routers, the strings are compared as they are, so 0043 does not match +43.
+// This is the rule used by the route matching and the overlap check of the linter.
+func HeadnumberMatches(headnumber string, number string) bool {
+	return headnumber != "" && strings.HasPrefix(number, headnumber)
+}
+
+// MatchRoute returns the rule selected for the request, or nil if no rule matches
+func MatchRoute(rd *RoutingData, request RouteRequest) *RouteMatch {
+	// the lookup tables of the routers
+	entries, shadowed := ResolveRoutes(rd.Rules)
+
+	var selected *RouteEntry
+	matching := []RouteEntry{}
+	for i, entry := range entries {
+		switch {
+		case entry.MatchType == MatchTypeDomain && request.Domain != "" && entry.Key == request.Domain:
+			selected = &entries[i]
+		case entry.MatchType == MatchTypeHeadnumber && HeadnumberMatches(entry.Key, request.Number):
+			matching = append(matching, entry)
+		}
+	}
+
+	// the headnumbers matching the number, the longest first
+	sort.SliceStable(matching, func(i, j int) bool {
+		return len(matching[i].Key) > len(matching[j].Key)
+	})
+
+	if selected == nil {
+		if len(matching) == 0 {
+			return nil
+		}
+		selected = &matching[0]
+		matching = matching[1:]
+	}
+
+	match := &RouteMatch{Rule: selected.Rule, MatchType: selected.MatchType}
+	for _, shadow := range shadowed {
+		if shadow.MatchType == selected.MatchType && shadow.Key == selected.Key {
+			match.Shadowed = append(match.Shadowed, shadow.Rule)
+		}
+	}
+
+	for _, entry := range matching {
+		if !reflect.DeepEqual(entry.Rule, match.Rule) {
+			match.Candidates = append(match.Candidates, entry.Rule)
+		}
+	}
+
+	return match
+}
@@ -0,0 +1,76 @@
+package controllers
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseSIPURI(t *testing.T) {
+	user, host, err := ParseSIPURI(`"Alice" <sips:+43512334455@SIP.example.com:5061;transport=tls>`)
+	assert.NoError(t, err)
+	assert.Equal(t, "+43512334455", user)
+	assert.Equal(t, "sip.example.com", host)
+
+	user, host, err = ParseSIPURI("tel:+43512334455")
+	assert.NoError(t, err)
+	assert.Equal(t, "+43512334455", user)
+	assert.Equal(t, "", host)
+
+	_, host, err = ParseSIPURI("sip:[2001:db8::1]:5060")
+	assert.NoError(t, err)
+	assert.Equal(t, "2001:db8::1", host)
+
+	_, _, err = ParseSIPURI("http://example.com")
+	assert.Error(t, err)
+}
+
+func TestNewRouteRequest(t *testing.T) {
+	// the number is taken from To, like the routers do
+	request, err := NewRouteRequest("sip:+43512999@sip.example.com", "<sip:+43512334455@sip.example.com>")
+	assert.NoError(t, err)
+	assert.Equal(t, RouteRequest{Domain: "sip.example.com", Number: "+43512334455"}, request)
+
+	request, err = NewRouteRequest("sip:+43512999@sip.example.com", "")
+	assert.NoError(t, err)
+	assert.Equal(t, RouteRequest{Domain: "sip.example.com", Number: "+43512999"}, request)
+}
+
+func TestMatchRoute(t *testing.T) {
+	rd := &RoutingData{Rules: []RoutingRule{
+		{Headnumber: "+43512", Owner: "a/short", Backend: "short.a"},
+		{Headnumber: "+43512334455", Owner: "a/long", Backend: "long.a"},
+		{Headnumber: "+43512334455", Owner: "b/long", Backend: "long.b"},
+		{Domain: "tenant.example.com", Owner: "c/domain", Backend: "domain.c"},
+		{Domain: "other.example.com", Headnumber: "+43512334455", Owner: "d/both", Backend: "both.d"},
+	}}
+
+	match := MatchRoute(rd, RouteRequest{Domain: "sip.example.com", Number: "+43512334455123"})
+	assert.Equal(t, "a/long", match.Rule.Owner)
+	assert.Equal(t, "headnumber", match.MatchType)
+	assert.Equal(t, []RoutingRule{rd.Rules[2], rd.Rules[4]}, match.Shadowed)
+	assert.Equal(t, []RoutingRule{rd.Rules[0]}, match.Candidates)
+
+	// the numbers are compared as they are, 00 is not the same as +
+	assert.Nil(t, MatchRoute(rd, RouteRequest{Domain: "sip.example.com", Number: "0043512334455123"}))
+
+	// a domain wins over any headnumber, even a longer one
+	match = MatchRoute(rd, RouteRequest{Domain: "tenant.example.com", Number: "+43512334455123"})
+	assert.Equal(t, "c/domain", match.Rule.Owner)
+	assert.Equal(t, "domain", match.MatchType)
+	assert.Equal(t, []RoutingRule{rd.Rules[1], rd.Rules[0]}, match.Candidates)
+
+	match = MatchRoute(rd, RouteRequest{Domain: "other.example.com", Number: "alice"})
+	assert.Equal(t, "d/both", match.Rule.Owner)
+	assert.Equal(t, "domain", match.MatchType)
+
+	assert.Nil(t, MatchRoute(rd, RouteRequest{Domain: "sip.example.com", Number: "+49301234"}))
+}
+
+func TestHeadnumberMatches(t *testing.T) {
+	assert.True(t, HeadnumberMatches("+43512", "+43512334455"))
+	assert.True(t, HeadnumberMatches("+43512", "+43512"))
+	assert.False(t, HeadnumberMatches("+43512", "0043512334455"))
+	assert.False(t, HeadnumberMatches("+43512334455", "+43512"))
+	assert.False(t, HeadnumberMatches("", "+43512"))
+}
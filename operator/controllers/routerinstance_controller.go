@@ -19,6 +19,7 @@ package controllers
 import (
 	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
 
 	"context"
 
@@ -41,6 +42,12 @@ type RouterInstanceReconciler struct {
 	client.Client
 	Scheme    *runtime.Scheme
 	Generator Generator
+
+	// RouterImage is the image of the kamailio container, Default_RouterImage if empty
+	RouterImage string
+
+	// ControllerImage is the image of the kasico controller sidecar, Default_ControllerImage if empty
+	ControllerImage string
 }
 
 //+kubebuilder:rbac:groups=kasico.world-direct.at,resources=routerinstances,verbs=get;list;watch;create;update;patch;delete
@@ -49,6 +56,9 @@ type RouterInstanceReconciler struct {
 //+kubebuilder:rbac:groups=apps,resources=daemonSet,verbs=get;list;watch;create;update;patch;delete
 //+kubebuilder:rbac:groups=core,resources=service,verbs=get;list;watch;create;update;patch;delete
 //+kubebuilder:rbac:groups=core,resources=configmap,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=core,resources=serviceaccounts,verbs=get;list;watch;create
+//+kubebuilder:rbac:groups=rbac.authorization.k8s.io,resources=rolebindings,verbs=get;list;watch;create
+//+kubebuilder:rbac:groups=rbac.authorization.k8s.io,resources=clusterroles,verbs=bind,resourceNames=kasico-controller-role
 
 // Reconcile is part of the main kubernetes reconciliation loop which aims to
 // move the current state of the cluster closer to the desired state.
@@ -62,10 +72,6 @@ func (r *RouterInstanceReconciler) Reconcile(ctx context.Context, req ctrl.Reque
 	log := ctrllog.FromContext(ctx)
 	log.V(2).Info("Reconcile RouterInstance")
 
-	// we notify the generator, because it implements debouncing, and implements the logic
-	// to check if an update is really needed.
-	r.Generator.OnObjectsChanged(ctx)
-
 	// Fetch the RouterInstance instance
 	routerInstance := &kasicov1.RouterInstance{}
 	err := r.Get(ctx, req.NamespacedName, routerInstance)
@@ -75,6 +81,7 @@ func (r *RouterInstanceReconciler) Reconcile(ctx context.Context, req ctrl.Reque
 			// Owned objects are automatically garbage collected. For additional cleanup logic use finalizers.
 			// Return and don't requeue
 			log.Info("RouterInstance resource not found. Ignoring since object must be deleted")
+			r.Generator.OnObjectsChanged(ctx, DescribeDeletion("RouterInstance", req.NamespacedName))
 			return ctrl.Result{}, nil
 		}
 		// Error reading the object - requeue the request.
@@ -82,6 +89,46 @@ func (r *RouterInstanceReconciler) Reconcile(ctx context.Context, req ctrl.Reque
 		return ctrl.Result{}, err
 	}
 
+	// we notify the generator, because it implements debouncing, and implements the logic
+	// to check if an update is really needed.
+	r.Generator.OnObjectsChanged(ctx, DescribeTrigger("RouterInstance", routerInstance))
+
+	// Check if the ServiceAccount of the sidecar already exists, if not create a new one
+	serviceAccount := &corev1.ServiceAccount{}
+	err = r.Get(ctx, types.NamespacedName{Name: Name_ServiceAccount, Namespace: routerInstance.Namespace}, serviceAccount)
+	if err != nil && errors.IsNotFound(err) {
+		serviceAccount := r.serviceAccountForRouterInstance(routerInstance)
+		log.Info("Creating a new ServiceAccount", "ServiceAccount.Namespace", serviceAccount.Namespace, "ServiceAccount.Name", serviceAccount.Name)
+		err = r.Create(ctx, serviceAccount)
+		if err != nil {
+			log.Error(err, "Failed to create new ServiceAccount", "ServiceAccount.Namespace", serviceAccount.Namespace, "ServiceAccount.Name", serviceAccount.Name)
+			return ctrl.Result{}, err
+		}
+		// ServiceAccount created successfully - return and requeue
+		return ctrl.Result{Requeue: true}, nil
+	} else if err != nil {
+		log.Error(err, "Failed to get ServiceAccount")
+		return ctrl.Result{}, err
+	}
+
+	// Check if the RoleBinding of the sidecar already exists, if not create a new one
+	roleBinding := &rbacv1.RoleBinding{}
+	err = r.Get(ctx, types.NamespacedName{Name: Name_ServiceAccount, Namespace: routerInstance.Namespace}, roleBinding)
+	if err != nil && errors.IsNotFound(err) {
+		roleBinding := r.roleBindingForRouterInstance(routerInstance)
+		log.Info("Creating a new RoleBinding", "RoleBinding.Namespace", roleBinding.Namespace, "RoleBinding.Name", roleBinding.Name)
+		err = r.Create(ctx, roleBinding)
+		if err != nil {
+			log.Error(err, "Failed to create new RoleBinding", "RoleBinding.Namespace", roleBinding.Namespace, "RoleBinding.Name", roleBinding.Name)
+			return ctrl.Result{}, err
+		}
+		// RoleBinding created successfully - return and requeue
+		return ctrl.Result{Requeue: true}, nil
+	} else if err != nil {
+		log.Error(err, "Failed to get RoleBinding")
+		return ctrl.Result{}, err
+	}
+
 	// Check if the DaemonSet already exists, if not create a new one
 	daemonSet := &appsv1.DaemonSet{}
 	err = r.Get(ctx, types.NamespacedName{Name: Name_Daemonset, Namespace: routerInstance.Namespace}, daemonSet)
@@ -165,6 +212,8 @@ func (r *RouterInstanceReconciler) SetupWithManager(mgr ctrl.Manager) error {
 		Owns(&appsv1.DaemonSet{}).
 		Owns(&corev1.Service{}).
 		Owns(&corev1.ConfigMap{}).
+		Owns(&corev1.ServiceAccount{}).
+		Owns(&rbacv1.RoleBinding{}).
 		Complete(r)
 }
 
@@ -204,12 +253,63 @@ func (r *RouterInstanceReconciler) daemonSetForRouterInstance(m *kasicov1.Router
 		})
 	}
 
+	image := r.RouterImage
+	if image == "" {
+		image = Default_RouterImage
+	}
+
+	controllerImage := r.ControllerImage
+	if controllerImage == "" {
+		controllerImage = Default_ControllerImage
+	}
+
+	// the configuration rendered by the sidecar, and the RPC socket of kamailio are shared
+	volumeMounts := []corev1.VolumeMount{
+		{Name: Name_Volume_Config, MountPath: Path_Config},
+		{Name: Name_Volume_Run, MountPath: Path_Run},
+	}
+
 	kamailioContainer := corev1.Container{
-		Image: "nginx",
-		Name:  Name_Container_Kamailio,
-		Ports: ports,
+		Image:        image,
+		Name:         Name_Container_Kamailio,
+		Ports:        ports,
+		VolumeMounts: volumeMounts,
+	}
+
+	// the generator and the sidecar render the same configuration
+	controllerArgs := []string{
+		"--namespace=$(POD_NAMESPACE)",
+		"--router-instance=" + m.Name,
+		"--config-dir=" + Path_Config,
+		"--sqlite=" + Path_Config + "/" + Name_ConfigMap + ".sqlite",
+	}
+	controllerEnv := []corev1.EnvVar{
+		{Name: "POD_NAME", ValueFrom: &corev1.EnvVarSource{FieldRef: &corev1.ObjectFieldSelector{FieldPath: "metadata.name"}}},
+		{Name: "POD_NAMESPACE", ValueFrom: &corev1.EnvVarSource{FieldRef: &corev1.ObjectFieldSelector{FieldPath: "metadata.namespace"}}},
+	}
+
+	// kamailio is only started, once the configuration has been rendered
+	generatorContainer := corev1.Container{
+		Image:        controllerImage,
+		Name:         Name_Container_Generator,
+		Command:      []string{"/manager"},
+		Args:         append([]string{"controller", "generate"}, controllerArgs...),
+		Env:          controllerEnv,
+		VolumeMounts: volumeMounts,
 	}
 
+	controllerContainer := corev1.Container{
+		Image:        controllerImage,
+		Name:         Name_Container_Controller,
+		Command:      []string{"/manager"},
+		Args:         append(append([]string{"controller", "watch"}, controllerArgs...), "--rpc=unix:"+Path_Run+"/kamailio_rpc.sock"),
+		Env:          controllerEnv,
+		VolumeMounts: volumeMounts,
+	}
+
+	// the sidecar signals and restarts kamailio, so the processes are visible to each other
+	shareProcessNamespace := true
+
 	daemonSet := &appsv1.DaemonSet{
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      Name_Daemonset,
@@ -224,7 +324,14 @@ func (r *RouterInstanceReconciler) daemonSetForRouterInstance(m *kasicov1.Router
 					Labels: ls,
 				},
 				Spec: corev1.PodSpec{
-					Containers: []corev1.Container{kamailioContainer},
+					ServiceAccountName:    Name_ServiceAccount,
+					ShareProcessNamespace: &shareProcessNamespace,
+					InitContainers:        []corev1.Container{generatorContainer},
+					Containers:            []corev1.Container{kamailioContainer, controllerContainer},
+					Volumes: []corev1.Volume{
+						{Name: Name_Volume_Config, VolumeSource: corev1.VolumeSource{EmptyDir: &corev1.EmptyDirVolumeSource{}}},
+						{Name: Name_Volume_Run, VolumeSource: corev1.VolumeSource{EmptyDir: &corev1.EmptyDirVolumeSource{}}},
+					},
 				},
 			},
 		},
@@ -235,6 +342,45 @@ func (r *RouterInstanceReconciler) daemonSetForRouterInstance(m *kasicov1.Router
 	return daemonSet
 }
 
+// serviceAccountForRouterInstance returns the ServiceAccount of the router pods
+func (r *RouterInstanceReconciler) serviceAccountForRouterInstance(m *kasicov1.RouterInstance) *corev1.ServiceAccount {
+	serviceAccount := &corev1.ServiceAccount{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      Name_ServiceAccount,
+			Namespace: m.Namespace,
+		},
+	}
+
+	// Set RouterInstance as the owner and controller
+	ctrl.SetControllerReference(m, serviceAccount, r.Scheme)
+	return serviceAccount
+}
+
+// roleBindingForRouterInstance returns the RoleBinding of the ServiceAccount of the
+// router pods to the controller role, so the sidecar can read its inputs in the namespace
+func (r *RouterInstanceReconciler) roleBindingForRouterInstance(m *kasicov1.RouterInstance) *rbacv1.RoleBinding {
+	roleBinding := &rbacv1.RoleBinding{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      Name_ServiceAccount,
+			Namespace: m.Namespace,
+		},
+		RoleRef: rbacv1.RoleRef{
+			APIGroup: rbacv1.GroupName,
+			Kind:     "ClusterRole",
+			Name:     Name_ControllerRole,
+		},
+		Subjects: []rbacv1.Subject{{
+			Kind:      rbacv1.ServiceAccountKind,
+			Name:      Name_ServiceAccount,
+			Namespace: m.Namespace,
+		}},
+	}
+
+	// Set RouterInstance as the owner and controller
+	ctrl.SetControllerReference(m, roleBinding, r.Scheme)
+	return roleBinding
+}
+
 // serviceForRouterInstance returns a kasicoRouter Service object
 func (r *RouterInstanceReconciler) serviceForRouterInstance(m *kasicov1.RouterInstance) *corev1.Service {
 	ls := routerPodLabels(m)
@@ -0,0 +1,69 @@
+package controllers
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+
+	kasicov1 "github.com/world-direct/kasico/operator/api/v1"
+)
+
+func TestDaemonSetForRouterInstance(t *testing.T) {
+	scheme := runtime.NewScheme()
+	assert.NoError(t, kasicov1.AddToScheme(scheme))
+
+	r := &RouterInstanceReconciler{Scheme: scheme, RouterImage: "kamailio/kamailio:5.6", ControllerImage: "kasico:1.0"}
+	router := &kasicov1.RouterInstance{ObjectMeta: metav1.ObjectMeta{Namespace: "kasico-default", Name: "router"}}
+	router.Spec.RouterService.UDPPort = 5060
+
+	daemonSet := r.daemonSetForRouterInstance(router)
+	spec := daemonSet.Spec.Template.Spec
+	assert.Equal(t, Name_ServiceAccount, spec.ServiceAccountName)
+	assert.Equal(t, true, *spec.ShareProcessNamespace)
+	assert.Len(t, daemonSet.OwnerReferences, 1)
+
+	assert.Len(t, spec.Volumes, 2)
+	for _, volume := range spec.Volumes {
+		assert.NotNil(t, volume.EmptyDir, volume.Name)
+	}
+
+	assert.Len(t, spec.Containers, 2)
+	kamailio, controller := spec.Containers[0], spec.Containers[1]
+	assert.Equal(t, "kamailio/kamailio:5.6", kamailio.Image)
+	assert.Equal(t, int32(5060), kamailio.Ports[0].ContainerPort)
+	assert.Equal(t, "kasico:1.0", controller.Image)
+	assert.Equal(t, kamailio.VolumeMounts, controller.VolumeMounts)
+	assert.Equal(t, corev1.VolumeMount{Name: Name_Volume_Config, MountPath: Path_Config}, controller.VolumeMounts[0])
+	assert.Equal(t, []string{
+		"controller", "watch",
+		"--namespace=$(POD_NAMESPACE)",
+		"--router-instance=router",
+		"--config-dir=/etc/kamailio",
+		"--sqlite=/etc/kamailio/routing-data.sqlite",
+		"--rpc=unix:/run/kamailio/kamailio_rpc.sock",
+	}, controller.Args)
+	assert.Equal(t, "POD_NAME", controller.Env[0].Name)
+
+	// the configuration is rendered before kamailio is started
+	assert.Len(t, spec.InitContainers, 1)
+	generator := spec.InitContainers[0]
+	assert.Equal(t, "kasico:1.0", generator.Image)
+	assert.Equal(t, controller.VolumeMounts, generator.VolumeMounts)
+	assert.Equal(t, controller.Env, generator.Env)
+	assert.Equal(t, []string{
+		"controller", "generate",
+		"--namespace=$(POD_NAMESPACE)",
+		"--router-instance=router",
+		"--config-dir=/etc/kamailio",
+		"--sqlite=/etc/kamailio/routing-data.sqlite",
+	}, generator.Args)
+	assert.Equal(t, "metadata.name", controller.Env[0].ValueFrom.FieldRef.FieldPath)
+	assert.Equal(t, "metadata.namespace", controller.Env[1].ValueFrom.FieldRef.FieldPath)
+
+	roleBinding := r.roleBindingForRouterInstance(router)
+	assert.Equal(t, Name_ControllerRole, roleBinding.RoleRef.Name)
+	assert.Equal(t, "kasico-default", roleBinding.Subjects[0].Namespace)
+}
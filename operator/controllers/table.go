@@ -0,0 +1,194 @@
+package controllers
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+
+	kasicov1 "github.com/world-direct/kasico/operator/api/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	ctrllog "sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// The routing table lists the lookup tables of the routers of a RouterInstance, after the conflicts
+// are resolved like in the routers (see ResolveRoutes), so it contains every domain and headnumber
+// which is actually routed, with the rule it is routed by.
+
+// RoutingTable are the entries of the lookup tables of a RouterInstance, the domains first
+type RoutingTable struct {
+	RouterInstance   string              `json:"routerInstance"`
+	IngressClassName string              `json:"ingressClassName"`
+	Generation       int                 `json:"generation"`
+	Entries          []RoutingTableEntry `json:"entries"`
+}
+
+// RoutingTableEntry is a domain or headnumber, with the rule winning it
+type RoutingTableEntry struct {
+	// Domain or Headnumber is set, depending on the MatchType
+	Domain     string `json:"domain,omitempty"`
+	Headnumber string `json:"headnumber,omitempty"`
+	MatchType  string `json:"matchType"`
+
+	// Ingress is the namespace/name of the Ingress owning the rule
+	Ingress   string   `json:"ingress"`
+	Backend   string   `json:"backend"`
+	Endpoints []string `json:"endpoints,omitempty"`
+}
+
+// The formats of WriteRoutingTables, with their content type
+var RoutingTableFormats = map[string]string{
+	"csv":      "text/csv; charset=utf-8",
+	"json":     "application/json",
+	"markdown": "text/markdown; charset=utf-8",
+}
+
+// NewRoutingTable returns the routing table of the routing-data of the RouterInstance
+func NewRoutingTable(router *kasicov1.RouterInstance, rd *RoutingData) RoutingTable {
+	table := RoutingTable{
+		RouterInstance:   router.Namespace + "/" + router.Name,
+		IngressClassName: router.Spec.IngressClassName,
+		Generation:       rd.Generation,
+		Entries:          []RoutingTableEntry{},
+	}
+
+	entries, _ := ResolveRoutes(rd.Rules)
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].MatchType == MatchTypeDomain && entries[j].MatchType != MatchTypeDomain
+	})
+
+	for _, entry := range entries {
+		tableEntry := RoutingTableEntry{
+			MatchType: entry.MatchType,
+			Ingress:   entry.Rule.Owner,
+			Backend:   entry.Rule.Backend,
+			Endpoints: entry.Rule.Endpoints,
+		}
+
+		if entry.MatchType == MatchTypeDomain {
+			tableEntry.Domain = entry.Key
+		} else {
+			tableEntry.Headnumber = entry.Key
+		}
+
+		table.Entries = append(table.Entries, tableEntry)
+	}
+
+	return table
+}
+
+// WriteRoutingTables writes the routing tables as csv, json or markdown
+func WriteRoutingTables(w io.Writer, format string, tables []RoutingTable) error {
+	switch format {
+	case "csv":
+		return writeRoutingTablesCSV(w, tables)
+	case "json":
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(tables)
+	case "markdown":
+		return writeRoutingTablesMarkdown(w, tables)
+	}
+
+	return fmt.Errorf("unknown format %s", format)
+}
+
+// writeRoutingTablesCSV writes a row per entry, with the RouterInstance in the first column
+func writeRoutingTablesCSV(w io.Writer, tables []RoutingTable) error {
+	writer := csv.NewWriter(w)
+	writer.Write([]string{"routerInstance", "domain", "headnumber", "matchType", "ingress", "backend", "endpoints"})
+
+	for _, table := range tables {
+		for _, entry := range table.Entries {
+			writer.Write([]string{table.RouterInstance, entry.Domain, entry.Headnumber, entry.MatchType,
+				entry.Ingress, entry.Backend, strings.Join(entry.Endpoints, " ")})
+		}
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
+
+// writeRoutingTablesMarkdown writes a section with a table per RouterInstance
+func writeRoutingTablesMarkdown(w io.Writer, tables []RoutingTable) error {
+	cell := func(value string) string {
+		return strings.ReplaceAll(value, "|", "\\|")
+	}
+
+	for i, table := range tables {
+		if i > 0 {
+			fmt.Fprintln(w)
+		}
+
+		fmt.Fprintf(w, "## RouterInstance %s\n\n", table.RouterInstance)
+		fmt.Fprintf(w, "Class %s, generation %d, %d entries\n\n", table.IngressClassName, table.Generation, len(table.Entries))
+		fmt.Fprintln(w, "| Domain | Headnumber | Match | Ingress | Backend | Endpoints |")
+		fmt.Fprintln(w, "|---|---|---|---|---|---|")
+
+		for _, entry := range table.Entries {
+			_, err := fmt.Fprintf(w, "| %s | %s | %s | %s | %s | %s |\n", cell(entry.Domain), cell(entry.Headnumber), entry.MatchType,
+				cell(entry.Ingress), cell(entry.Backend), cell(strings.Join(entry.Endpoints, ", ")))
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// RoutingTableHandler serves the routing tables of the published routing-data.
+// The query parameters are the format (csv, json or markdown, json if not set),
+// and optionally the namespace and the name of the RouterInstance.
+// The handler does no authorization, and exposes the routing of all tenants. It must only be
+// served behind the auth proxy, like the metrics, which needs a get on the '/routing-table' URL.
+type RoutingTableHandler struct {
+	Client client.Reader
+}
+
+func (handler *RoutingTableHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	log := ctrllog.FromContext(r.Context())
+	query := r.URL.Query()
+
+	format := query.Get("format")
+	if format == "" {
+		format = "json"
+	}
+
+	contentType, ok := RoutingTableFormats[format]
+	if !ok {
+		http.Error(w, fmt.Sprintf("unknown format %s", format), http.StatusBadRequest)
+		return
+	}
+
+	routers := &kasicov1.RouterInstanceList{}
+	err := handler.Client.List(r.Context(), routers, client.InNamespace(query.Get("namespace")))
+	if err != nil {
+		log.Error(err, "Unable to list the RouterInstances")
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	tables := []RoutingTable{}
+	for i := range routers.Items {
+		router := &routers.Items[i]
+		if name := query.Get("router"); name != "" && router.Name != name {
+			continue
+		}
+
+		rd, err := PublishedRoutingData(r.Context(), handler.Client, router)
+		if err != nil {
+			log.Error(err, "Unable to read the routing-data", "RouterInstance", router.Name)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		tables = append(tables, NewRoutingTable(router, rd))
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	WriteRoutingTables(w, format, tables)
+}
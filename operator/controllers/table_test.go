@@ -0,0 +1,68 @@
+package controllers
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	kasicov1 "github.com/world-direct/kasico/operator/api/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestNewRoutingTable(t *testing.T) {
+	router := &kasicov1.RouterInstance{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "kasico", Name: "router"},
+		Spec:       kasicov1.RouterInstanceSpec{IngressClassName: "default"},
+	}
+	rd := &RoutingData{Generation: 3, Rules: []RoutingRule{
+		{Headnumber: "+43512", Owner: "a/short", Backend: "short.a"},
+		{Headnumber: "+43512", Owner: "b/short", Backend: "short.b"},
+		{Domain: "sip.example.com", Owner: "c/domain", Backend: "domain.c", Endpoints: []string{"192.0.2.1:5060", "192.0.2.2:5060"}},
+	}}
+
+	table := NewRoutingTable(router, rd)
+	assert.Equal(t, "kasico/router", table.RouterInstance)
+	assert.Equal(t, 3, table.Generation)
+	assert.Equal(t, []RoutingTableEntry{
+		{Domain: "sip.example.com", MatchType: "domain", Ingress: "c/domain", Backend: "domain.c", Endpoints: []string{"192.0.2.1:5060", "192.0.2.2:5060"}},
+		{Headnumber: "+43512", MatchType: "headnumber", Ingress: "a/short", Backend: "short.a"},
+	}, table.Entries)
+
+	out := &bytes.Buffer{}
+	assert.NoError(t, WriteRoutingTables(out, "csv", []RoutingTable{table}))
+	assert.Equal(t, `routerInstance,domain,headnumber,matchType,ingress,backend,endpoints
+kasico/router,sip.example.com,,domain,c/domain,domain.c,192.0.2.1:5060 192.0.2.2:5060
+kasico/router,,+43512,headnumber,a/short,short.a,
+`, out.String())
+
+	out.Reset()
+	assert.NoError(t, WriteRoutingTables(out, "markdown", []RoutingTable{table}))
+	assert.Contains(t, out.String(), "| sip.example.com |  | domain | c/domain | domain.c | 192.0.2.1:5060, 192.0.2.2:5060 |\n")
+
+	assert.Error(t, WriteRoutingTables(out, "xml", []RoutingTable{table}))
+}
+
+func TestNewRoutingTable_Conflicts(t *testing.T) {
+	router := &kasicov1.RouterInstance{ObjectMeta: metav1.ObjectMeta{Namespace: "kasico", Name: "router"}}
+
+	// every call to a.com is routed to X, +432 only for the other domains to Y
+	table := NewRoutingTable(router, &RoutingData{Rules: []RoutingRule{
+		{Domain: "a.com", Headnumber: "+431", Owner: "a/x", Backend: "x.a"},
+		{Domain: "a.com", Headnumber: "+432", Owner: "a/y", Backend: "y.a"},
+	}})
+	assert.Equal(t, []RoutingTableEntry{
+		{Domain: "a.com", MatchType: "domain", Ingress: "a/x", Backend: "x.a"},
+		{Headnumber: "+431", MatchType: "headnumber", Ingress: "a/x", Backend: "x.a"},
+		{Headnumber: "+432", MatchType: "headnumber", Ingress: "a/y", Backend: "y.a"},
+	}, table.Entries)
+
+	// the headnumber +43 is routed to X, independent of the domain
+	table = NewRoutingTable(router, &RoutingData{Rules: []RoutingRule{
+		{Headnumber: "+43", Owner: "a/x", Backend: "x.a"},
+		{Domain: "b.com", Headnumber: "+43", Owner: "a/y", Backend: "y.a"},
+	}})
+	assert.Equal(t, []RoutingTableEntry{
+		{Domain: "b.com", MatchType: "domain", Ingress: "a/y", Backend: "y.a"},
+		{Headnumber: "+43", MatchType: "headnumber", Ingress: "a/x", Backend: "x.a"},
+	}, table.Entries)
+}
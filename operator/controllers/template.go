@@ -6,6 +6,10 @@ type RoutingData struct {
 	AdvertiseAddress string
 	Generation       int
 	Rules            []RoutingRule
+
+	// Secrets are only set by the sidecar while rendering, from the Secrets referenced by the
+	// RouterInstance. They are never part of the published routing-data.
+	Secrets map[string]string `json:"-"`
 }
 
 type RoutingRule struct {
@@ -13,4 +17,7 @@ type RoutingRule struct {
 	Headnumber string
 	Owner      string
 	Backend    string
+
+	// Endpoints is only set if the RouterInstance resolves endpoints
+	Endpoints []string `json:",omitempty"`
 }
@@ -1,12 +1,19 @@
 package controllers
 
 import (
+	"context"
 	"crypto/md5"
 	"encoding/hex"
+	"encoding/json"
+	"fmt"
 	"sort"
 
 	kasicov1 "github.com/world-direct/kasico/operator/api/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 )
 
 // HashStringMap returns a string representing a Hash over the name
@@ -30,39 +37,167 @@ func HashStringMap(items map[string]string) string {
 	return final
 }
 
-func GetRoutingData(routerInstance kasicov1.RouterInstance, allIngresses []kasicov1.Ingress) *RoutingData {
+// GetRoutingData maps all Ingresses matching the IngressClassName of the routerInstance
+// into the RoutingData. The rules are sorted, so that the same set of Ingresses always
+// results in the same RoutingData, independent of the order they have been listed.
+// The Generation is taken from the status of the routerInstance.
+// Rules to backend Services which don't exist in backends are dropped.
+func GetRoutingData(routerInstance kasicov1.RouterInstance, allIngresses []kasicov1.Ingress, backends *Backends) *RoutingData {
 
 	rd := &RoutingData{
 		UDPPort:          routerInstance.Spec.RouterService.UDPPort,
 		TCPPort:          routerInstance.Spec.RouterService.TCPPort,
 		AdvertiseAddress: routerInstance.Spec.RouterService.AdvertiseAddress,
-		Generation:       0,
+		Generation:       routerInstance.Status.RoutingDataGeneration,
 	}
 
 	rules := []RoutingRule{}
 	for _, ingress := range allIngresses {
 
-		if ingress.Spec.IngressClassName != routerInstance.Spec.IngressClassName {
+		if ingress.Spec.IngressClassName != routerInstance.Spec.IngressClassName || IsDryRun(&ingress) {
 			continue
 		}
 
 		owner := ingress.Namespace + "/" + ingress.Name
 		for _, rule := range ingress.Spec.Rules {
-			rules = append(rules, RoutingRule{
+			if !backends.HasService(ingress.Namespace, rule.Backend.Service.Name) {
+				continue
+			}
+
+			routingRule := RoutingRule{
 				Owner:      owner,
 				Domain:     rule.Sip.Domain,
 				Headnumber: rule.Sip.Headnumber,
 				Backend:    rule.Backend.Service.Name + "." + ingress.Namespace,
-			})
+			}
+
+			if routerInstance.Spec.ResolveEndpoints {
+				routingRule.Endpoints = backends.Endpoints(ingress.Namespace, rule.Backend.Service.Name, rule.Backend.Service.Port)
+			}
+
+			rules = append(rules, routingRule)
 		}
 	}
 
+	SortRoutingRules(rules)
 	rd.Rules = rules
 
 	return rd
 
 }
 
+// SortRoutingRules sorts the rules into the canonical order
+func SortRoutingRules(rules []RoutingRule) {
+	sort.SliceStable(rules, func(i, j int) bool {
+		return lessRoutingRule(&rules[i], &rules[j])
+	})
+}
+
+// IsDryRun returns true if the Ingress should only be previewed
+func IsDryRun(ingress *kasicov1.Ingress) bool {
+	return GetAnnotation(&ingress.ObjectMeta, Name_AnnotationDryRun) == "true"
+}
+
+func lessRoutingRule(a *RoutingRule, b *RoutingRule) bool {
+	if a.Domain != b.Domain {
+		return a.Domain < b.Domain
+	}
+
+	if a.Headnumber != b.Headnumber {
+		return a.Headnumber < b.Headnumber
+	}
+
+	if a.Owner != b.Owner {
+		return a.Owner < b.Owner
+	}
+
+	return a.Backend < b.Backend
+}
+
+// MarshalRoutingData returns the canonical JSON representation of the RoutingData,
+// as it is written into the routing-data ConfigMap
+func MarshalRoutingData(rd *RoutingData) (string, error) {
+	bytes, err := json.MarshalIndent(rd, "", "  ")
+	if err != nil {
+		return "", err
+	}
+
+	return string(bytes), nil
+}
+
+// UnmarshalRoutingData parses the JSON representation of the RoutingData
+func UnmarshalRoutingData(data string) (*RoutingData, error) {
+	rd := &RoutingData{}
+	err := json.Unmarshal([]byte(data), rd)
+	if err != nil {
+		return nil, err
+	}
+
+	return rd, nil
+}
+
+// PublishedRoutingData reads the routing-data ConfigMap of the RouterInstance, which is empty if not yet published
+func PublishedRoutingData(ctx context.Context, c client.Reader, router *kasicov1.RouterInstance) (*RoutingData, error) {
+	cm := &corev1.ConfigMap{}
+	err := c.Get(ctx, types.NamespacedName{Namespace: router.Namespace, Name: Name_ConfigMap}, cm)
+	if errors.IsNotFound(err) {
+		return &RoutingData{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	routingData, err := UnmarshalRoutingData(cm.Data[Name_RouningDataJson])
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse the routing-data of %s/%s: %w", router.Namespace, router.Name, err)
+	}
+
+	return routingData, nil
+}
+
+// HashRoutingData returns the hash over the content of the RoutingData.
+// The Generation is not included, because it is derived from changes of this hash.
+func HashRoutingData(rd *RoutingData) (string, error) {
+	content := *rd
+	content.Generation = 0
+
+	json, err := MarshalRoutingData(&content)
+	if err != nil {
+		return "", err
+	}
+
+	return HashStringMap(map[string]string{Name_RouningDataJson: json}), nil
+}
+
+// DescribeTrigger returns a short description of a change of obj, used to record
+// what triggered a routing-data revision. The manager of the most recent change
+// is taken from the managed fields.
+func DescribeTrigger(kind string, obj client.Object) string {
+	trigger := kind + " " + obj.GetNamespace() + "/" + obj.GetName()
+
+	var latest *metav1.ManagedFieldsEntry
+	for i, entry := range obj.GetManagedFields() {
+		if entry.Time == nil {
+			continue
+		}
+
+		if latest == nil || latest.Time.Before(entry.Time) {
+			latest = &obj.GetManagedFields()[i]
+		}
+	}
+
+	if latest != nil {
+		trigger += " (by " + latest.Manager + ")"
+	}
+
+	return trigger
+}
+
+// DescribeDeletion returns a short description of the deletion of an object
+func DescribeDeletion(kind string, name types.NamespacedName) string {
+	return kind + " " + name.String() + " (deleted)"
+}
+
 func SetLabel(metadata *metav1.ObjectMeta, key string, value string) {
 	if metadata.Labels == nil {
 		metadata.Labels = make(map[string]string)
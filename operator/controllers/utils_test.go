@@ -4,6 +4,10 @@ import (
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	kasicov1 "github.com/world-direct/kasico/operator/api/v1"
+	corev1 "k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
 func TestHashStringMap_Empty(t *testing.T) {
@@ -81,3 +85,76 @@ func TestHashStringMap_NormalizedSortOrder(t *testing.T) {
 	h2 := HashStringMap(m2)
 	assert.Equal(t, h1, h2)
 }
+
+func testIngress(namespace string, name string, class string, rules ...kasicov1.IngressRule) kasicov1.Ingress {
+	return kasicov1.Ingress{
+		ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: name},
+		Spec:       kasicov1.IngressSpec{IngressClassName: class, Rules: rules},
+	}
+}
+
+func testIngressRule(domain string, headnumber string, service string) kasicov1.IngressRule {
+	return kasicov1.IngressRule{
+		Sip:     kasicov1.IngressRuleSip{Domain: domain, Headnumber: headnumber},
+		Backend: kasicov1.IngressBackend{Service: kasicov1.IngressBackendService{Name: service}},
+	}
+}
+
+func TestGetRoutingData_CanonicalOrder(t *testing.T) {
+	router := kasicov1.RouterInstance{Spec: kasicov1.RouterInstanceSpec{IngressClassName: "default"}}
+
+	i1 := testIngress("default", "a", "default", testIngressRule("b.example.org", "+432", "s1"), testIngressRule("a.example.org", "+431", "s1"))
+	i2 := testIngress("default", "b", "default", testIngressRule("a.example.org", "+430", "s2"))
+	i3 := testIngress("default", "c", "other", testIngressRule("a.example.org", "+439", "s3"))
+
+	rd1 := GetRoutingData(router, []kasicov1.Ingress{i1, i2, i3}, nil)
+	rd2 := GetRoutingData(router, []kasicov1.Ingress{i3, i2, i1}, nil)
+
+	assert.Equal(t, rd1, rd2)
+	assert.Equal(t, 3, len(rd1.Rules))
+	assert.Equal(t, "+430", rd1.Rules[0].Headnumber)
+	assert.Equal(t, "+431", rd1.Rules[1].Headnumber)
+	assert.Equal(t, "b.example.org", rd1.Rules[2].Domain)
+}
+
+func TestHashRoutingData_IgnoresGeneration(t *testing.T) {
+	rd1 := &RoutingData{UDPPort: 5060, Generation: 1}
+	rd2 := &RoutingData{UDPPort: 5060, Generation: 2}
+
+	h1, err := HashRoutingData(rd1)
+	assert.NoError(t, err)
+	h2, err := HashRoutingData(rd2)
+	assert.NoError(t, err)
+	assert.Equal(t, h1, h2)
+
+	rd2.UDPPort = 5080
+	h2, err = HashRoutingData(rd2)
+	assert.NoError(t, err)
+	assert.NotEqual(t, h1, h2)
+}
+
+func TestGetRoutingData_Backends(t *testing.T) {
+	router := kasicov1.RouterInstance{Spec: kasicov1.RouterInstanceSpec{IngressClassName: "default", ResolveEndpoints: true}}
+	ingress := testIngress("default", "a", "default", testIngressRule("a.example.org", "+431", "s1"), testIngressRule("a.example.org", "+432", "missing"))
+
+	ready := true
+	notReady := false
+	port := int32(5060)
+	backends := &Backends{
+		Services: []corev1.Service{{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "s1"}}},
+		EndpointSlices: []discoveryv1.EndpointSlice{{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "s1-abc", Labels: map[string]string{discoveryv1.LabelServiceName: "s1"}},
+			Ports:      []discoveryv1.EndpointPort{{Port: &port}},
+			Endpoints: []discoveryv1.Endpoint{
+				{Addresses: []string{"10.0.0.2"}, Conditions: discoveryv1.EndpointConditions{Ready: &ready}},
+				{Addresses: []string{"10.0.0.1"}},
+				{Addresses: []string{"10.0.0.3"}, Conditions: discoveryv1.EndpointConditions{Ready: &notReady}},
+			},
+		}},
+	}
+
+	rd := GetRoutingData(router, []kasicov1.Ingress{ingress}, backends)
+	assert.Equal(t, 1, len(rd.Rules))
+	assert.Equal(t, "s1.default", rd.Rules[0].Backend)
+	assert.Equal(t, []string{"10.0.0.1:5060", "10.0.0.2:5060"}, rd.Rules[0].Endpoints)
+}
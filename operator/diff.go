@@ -0,0 +1,225 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/spf13/cobra"
+	corev1 "k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	kasicov1 "github.com/world-direct/kasico/operator/api/v1"
+	"github.com/world-direct/kasico/operator/controllers"
+	"github.com/world-direct/kasico/operator/manifests"
+)
+
+type diffOptions struct {
+	allNamespaces bool
+	filenames     []string
+	routerName    string
+	mergeLive     bool
+	exitCode      bool
+	output        string
+}
+
+func newDiffCommand() *cobra.Command {
+	var opts diffOptions
+	diffCmd := &cobra.Command{
+		Use:   "diff [files or directories...]",
+		Short: "Shows the routing changes of local manifests, compared to the cluster",
+		Long: `Computes the routing-data of every RouterInstance from the local manifests, and shows the domains
+and headnumbers which would be added, removed, moved to another Ingress, or changed to another backend,
+compared to the published routing-data in the cluster. Like in the routers, only the first rule
+of a domain or headnumber is used.
+
+Without --merge-live, the manifests are the complete set of Ingresses and Services, e.g. a GitOps
+repository. With --merge-live, they are applied on top of the objects in the cluster.
+RouterInstances missing in the manifests are taken from the cluster. Manifests without namespace
+are in the --namespace. The published rules of dry-run Ingresses are kept, like by the operator.
+The endpoints are only compared if the manifests contain EndpointSlices, or with --merge-live.`,
+		Example: `  kasico diff ingresses/
+  kasico diff --merge-live -f tenant-a.yaml --exit-code`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return main_diff(cmd.Context(), cmd.OutOrStdout(), opts, args)
+		},
+	}
+
+	diffCmd.Flags().BoolVarP(&opts.allNamespaces, "all-namespaces", "A", false, "Compare the RouterInstances of all namespaces")
+	diffCmd.Flags().StringSliceVarP(&opts.filenames, "filename", "f", nil, "The files or directories containing the manifests, in addition to the arguments")
+	diffCmd.Flags().StringVar(&opts.routerName, "router", "", "The name of the RouterInstance, all if empty")
+	diffCmd.Flags().BoolVar(&opts.mergeLive, "merge-live", false, "Apply the manifests on top of the Ingresses, Services and EndpointSlices in the cluster")
+	diffCmd.Flags().BoolVar(&opts.exitCode, "exit-code", false, "Exit with 1 if there are differences")
+	diffCmd.Flags().StringVarP(&opts.output, "output", "o", "text", "The output format: text or json")
+
+	return diffCmd
+}
+
+// routerDiff is the diff of a RouterInstance
+type routerDiff struct {
+	RouterInstance string                       `json:"routerInstance"`
+	Generation     int                          `json:"generation"`
+	Diff           *controllers.RoutingDataDiff `json:"diff"`
+}
+
+func main_diff(ctx context.Context, out io.Writer, opts diffOptions, args []string) error {
+	paths := append(opts.filenames, args...)
+	if len(paths) == 0 {
+		return fmt.Errorf("no manifests given")
+	}
+
+	namespace, err := currentNamespace()
+	if err != nil {
+		return err
+	}
+
+	set, err := manifests.Load(namespace, paths...)
+	if err != nil {
+		return err
+	}
+
+	routersNamespace, err := listNamespace(opts.allNamespaces)
+	if err != nil {
+		return err
+	}
+
+	c, err := newClient()
+	if err != nil {
+		return err
+	}
+
+	live, err := liveRoutingData(ctx, c, routersNamespace, opts.routerName)
+	if err != nil {
+		return err
+	}
+
+	if opts.mergeLive {
+		set, err = mergeLiveObjects(ctx, c, set)
+		if err != nil {
+			return err
+		}
+	}
+
+	diffs := []routerDiff{}
+	for _, published := range live {
+		router := published.Router
+		for _, local := range set.RouterInstances {
+			if local.Namespace == router.Namespace && local.Name == router.Name {
+				router = local
+			}
+		}
+
+		// the rules of dry-run Ingresses stay published, like in the generator
+		proposed := controllers.ProposedRoutingData(router, set.Ingresses, set.Backends(), published.RoutingData)
+		current := published.RoutingData
+
+		// without EndpointSlices, the endpoints of the manifests are unknown and not compared
+		if len(set.EndpointSlices) == 0 {
+			proposed, current = proposed.WithoutEndpoints(), current.WithoutEndpoints()
+		}
+
+		diffs = append(diffs, routerDiff{
+			RouterInstance: router.Namespace + "/" + router.Name,
+			Generation:     published.RoutingData.Generation,
+			Diff:           controllers.DiffRoutingData(current, proposed),
+		})
+	}
+
+	switch opts.output {
+	case "json":
+		err = printJSON(out, diffs)
+	case "text":
+		for _, diff := range diffs {
+			printRouterDiff(out, &diff)
+		}
+	default:
+		err = fmt.Errorf("unknown output format %s", opts.output)
+	}
+	if err != nil {
+		return err
+	}
+
+	for _, diff := range diffs {
+		if opts.exitCode && !diff.Diff.Empty() {
+			return fmt.Errorf("the routing-data of %s differs", diff.RouterInstance)
+		}
+	}
+
+	return nil
+}
+
+// mergeLiveObjects returns the Ingresses, Services and EndpointSlices of the cluster,
+// replaced by the objects with the same name in the set
+func mergeLiveObjects(ctx context.Context, c client.Reader, set *manifests.Set) (*manifests.Set, error) {
+	ingresses := &kasicov1.IngressList{}
+	if err := c.List(ctx, ingresses); err != nil {
+		return nil, err
+	}
+
+	services := &corev1.ServiceList{}
+	if err := c.List(ctx, services); err != nil {
+		return nil, err
+	}
+
+	endpointSlices := &discoveryv1.EndpointSliceList{}
+	if err := c.List(ctx, endpointSlices); err != nil {
+		return nil, err
+	}
+
+	merged := *set
+	merged.Ingresses = mergeObjects(ingresses.Items, set.Ingresses)
+	merged.Services = mergeObjects(services.Items, set.Services)
+	merged.EndpointSlices = mergeObjects(endpointSlices.Items, set.EndpointSlices)
+	return &merged, nil
+}
+
+// mergeObjects returns the live objects, which are replaced by the local ones with the same name
+func mergeObjects[T any, PT interface {
+	*T
+	client.Object
+}](live []T, local []T) []T {
+	key := func(obj *T) string {
+		o := PT(obj)
+		return o.GetNamespace() + "/" + o.GetName()
+	}
+
+	localKeys := map[string]bool{}
+	for i := range local {
+		localKeys[key(&local[i])] = true
+	}
+
+	merged := []T{}
+	for i := range live {
+		if !localKeys[key(&live[i])] {
+			merged = append(merged, live[i])
+		}
+	}
+
+	return append(merged, local...)
+}
+
+func printRouterDiff(out io.Writer, diff *routerDiff) {
+	fmt.Fprintf(out, "RouterInstance %s (generation %d)\n", diff.RouterInstance, diff.Generation)
+
+	if diff.Diff.Empty() {
+		fmt.Fprintln(out, "  no changes")
+		return
+	}
+
+	for _, rule := range diff.Diff.Added {
+		fmt.Fprintf(out, "  + %s\n", rule)
+	}
+
+	for _, rule := range diff.Diff.Removed {
+		fmt.Fprintf(out, "  - %s\n", rule)
+	}
+
+	for _, change := range diff.Diff.Moved {
+		fmt.Fprintf(out, "  ~ moved:   %s\n           => %s\n", change.Old, change.New)
+	}
+
+	for _, change := range diff.Diff.Changed {
+		fmt.Fprintf(out, "  ~ changed: %s\n           => %s\n", change.Old, change.New)
+	}
+}
@@ -0,0 +1,229 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/spf13/cobra"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/yaml"
+
+	kasicov1 "github.com/world-direct/kasico/operator/api/v1"
+	"github.com/world-direct/kasico/operator/lint"
+	"github.com/world-direct/kasico/operator/manifests"
+)
+
+type importOptions struct {
+	csv       string
+	class     string
+	filenames []string
+	out       string
+	apply     bool
+	force     bool
+	lint      lint.Options
+}
+
+func newImportCommand() *cobra.Command {
+	var opts importOptions
+	importCmd := &cobra.Command{
+		Use:   "import",
+		Short: "Creates Ingresses from the numbers and domains of a CSV file",
+		Long: `Reads the rules of a CSV file, and groups them into an Ingress per namespace and tenant.
+The header row names the columns: ` + manifests.ColumnDomain + `, ` + manifests.ColumnHeadnumber + `, ` + manifests.ColumnService + `, and optionally ` +
+			manifests.ColumnNamespace + `, ` + manifests.ColumnClass + ` and ` + manifests.ColumnTenant + `.
+Rows without namespace are in the --namespace, rows without class have the --class, and the tenant
+defaults to the backend service. Duplicate rows of a tenant are dropped.
+
+The Ingresses are validated like 'kasico lint', together with the manifests given by --filename,
+e.g. the RouterInstances and backend Services. Without Services, the missing-service check is skipped.
+If errors are found, nothing is written or applied.
+
+The Ingresses are written to stdout, to a file per Ingress in the --out directory, or applied
+to the cluster with server-side apply.`,
+		Example: `  kasico import --csv numbers.csv --out ingresses/
+  kasico import --csv numbers.csv -n tenants --class carrier-a --apply`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return main_import(cmd.Context(), cmd.OutOrStdout(), cmd.ErrOrStderr(), opts)
+		},
+	}
+
+	importCmd.Flags().StringVar(&opts.csv, "csv", "", "The CSV file to import, - for stdin")
+	importCmd.Flags().StringVar(&opts.class, "class", "default", "The ingress class of rows without class")
+	importCmd.Flags().StringSliceVarP(&opts.filenames, "filename", "f", nil, "The files or directories containing manifests to validate the Ingresses with")
+	importCmd.Flags().StringVar(&opts.out, "out", "", "The directory to write a file per Ingress to")
+	importCmd.Flags().BoolVar(&opts.apply, "apply", false, "Apply the Ingresses to the cluster with server-side apply")
+	importCmd.Flags().BoolVar(&opts.force, "force-conflicts", false, "Take over the fields of the Ingresses managed by others when applying")
+	importCmd.Flags().StringSliceVar(&opts.lint.Skip, "skip", nil, "The lint checks to skip")
+	importCmd.MarkFlagRequired("csv")
+
+	return importCmd
+}
+
+// importFieldManager is the field manager of the applied Ingresses
+const importFieldManager = "kasico-import"
+
+func main_import(ctx context.Context, out io.Writer, errOut io.Writer, opts importOptions) error {
+	if opts.apply && opts.out != "" {
+		return fmt.Errorf("--apply and --out can't be used together")
+	}
+
+	namespace, err := currentNamespace()
+	if err != nil {
+		return err
+	}
+
+	reader := io.Reader(os.Stdin)
+	if opts.csv != "-" {
+		file, err := os.Open(opts.csv)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		reader = file
+	}
+
+	result, err := manifests.ReadIngressCSV(reader, manifests.CSVOptions{Namespace: namespace, IngressClassName: opts.class})
+	if err != nil {
+		return fmt.Errorf("unable to read %s: %w", opts.csv, err)
+	}
+
+	set, err := manifests.Load(namespace, opts.filenames...)
+	if err != nil {
+		return err
+	}
+
+	// the imported Ingresses replace the ones with the same name in the manifests
+	set.Ingresses = mergeObjects(set.Ingresses, result.Ingresses)
+	if len(set.Services) == 0 {
+		opts.lint.Skip = append(opts.lint.Skip, lint.CheckMissingService)
+	}
+
+	findings := importFindings(lint.Lint(set, opts.lint), result.Ingresses)
+	for _, finding := range findings {
+		fmt.Fprintln(errOut, finding)
+	}
+	fmt.Fprintf(errOut, "%d rows, %d duplicates dropped: %d Ingresses\n", result.Rows, result.Duplicates, len(result.Ingresses))
+
+	if errors := lint.Errors(findings); errors > 0 {
+		return fmt.Errorf("the Ingresses have %d errors", errors)
+	}
+
+	switch {
+	case opts.apply:
+		return applyIngresses(ctx, out, result.Ingresses, opts.force)
+	case opts.out != "":
+		return writeIngresses(out, opts.out, result.Ingresses)
+	}
+
+	for i := range result.Ingresses {
+		bytes, err := marshalIngress(&result.Ingresses[i])
+		if err != nil {
+			return err
+		}
+
+		fmt.Fprintf(out, "---\n%s", bytes)
+	}
+
+	return nil
+}
+
+// importFindings returns the findings of the imported Ingresses, and the conflicts of the manifests with them
+func importFindings(findings []lint.Finding, ingresses []kasicov1.Ingress) []lint.Finding {
+	imported := map[string]bool{}
+	for _, ingress := range ingresses {
+		imported["Ingress "+ingress.Namespace+"/"+ingress.Name] = true
+	}
+
+	result := []lint.Finding{}
+	for _, finding := range findings {
+		if imported[finding.Object] || imported[finding.Related] {
+			result = append(result, finding)
+		}
+	}
+
+	return result
+}
+
+// ingressObject returns the Ingress as unstructured object, without status and empty fields
+func ingressObject(ingress *kasicov1.Ingress) (*unstructured.Unstructured, error) {
+	content, err := runtime.DefaultUnstructuredConverter.ToUnstructured(ingress)
+	if err != nil {
+		return nil, err
+	}
+
+	obj := &unstructured.Unstructured{Object: content}
+	unstructured.RemoveNestedField(obj.Object, "status")
+	unstructured.RemoveNestedField(obj.Object, "metadata", "creationTimestamp")
+	return obj, nil
+}
+
+func marshalIngress(ingress *kasicov1.Ingress) ([]byte, error) {
+	obj, err := ingressObject(ingress)
+	if err != nil {
+		return nil, err
+	}
+
+	return yaml.Marshal(obj.Object)
+}
+
+// writeIngresses writes every Ingress to the file 'namespace_name.yaml' in the directory
+func writeIngresses(out io.Writer, directory string, ingresses []kasicov1.Ingress) error {
+	err := os.MkdirAll(directory, 0755)
+	if err != nil {
+		return err
+	}
+
+	for i := range ingresses {
+		ingress := &ingresses[i]
+		bytes, err := marshalIngress(ingress)
+		if err != nil {
+			return err
+		}
+
+		path := filepath.Join(directory, ingress.Namespace+"_"+ingress.Name+".yaml")
+		err = os.WriteFile(path, bytes, 0644)
+		if err != nil {
+			return err
+		}
+
+		fmt.Fprintf(out, "%s written\n", path)
+	}
+
+	return nil
+}
+
+// applyIngresses applies the Ingresses with server-side apply, so the rules of the imported
+// Ingresses are owned by the import, and other fields remain untouched
+func applyIngresses(ctx context.Context, out io.Writer, ingresses []kasicov1.Ingress, force bool) error {
+	c, err := newClient()
+	if err != nil {
+		return err
+	}
+
+	patchOptions := []client.PatchOption{client.FieldOwner(importFieldManager)}
+	if force {
+		patchOptions = append(patchOptions, client.ForceOwnership)
+	}
+
+	for i := range ingresses {
+		obj, err := ingressObject(&ingresses[i])
+		if err != nil {
+			return err
+		}
+
+		err = c.Patch(ctx, obj, client.Apply, patchOptions...)
+		if err != nil {
+			return fmt.Errorf("unable to apply the Ingress %s/%s: %w", obj.GetNamespace(), obj.GetName(), err)
+		}
+
+		fmt.Fprintf(out, "ingress %s/%s applied\n", obj.GetNamespace(), obj.GetName())
+	}
+
+	return nil
+}
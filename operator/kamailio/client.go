@@ -0,0 +1,152 @@
+// Package kamailio implements a client for the JSON-RPC interface of kamailio,
+// as provided by the jsonrpcs module. The unix datagram socket, FIFO and HTTP
+// (xhttp) transports of the module are supported.
+package kamailio
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// Transport sends a JSON-RPC request to kamailio, and returns the response
+type Transport interface {
+	RoundTrip(ctx context.Context, request []byte) ([]byte, error)
+}
+
+type request struct {
+	JSONRPC string        `json:"jsonrpc"`
+	Method  string        `json:"method"`
+	Params  []interface{} `json:"params,omitempty"`
+	ID      int64         `json:"id"`
+}
+
+type response struct {
+	JSONRPC string          `json:"jsonrpc"`
+	Result  json.RawMessage `json:"result,omitempty"`
+	Error   *Error          `json:"error,omitempty"`
+	ID      int64           `json:"id"`
+}
+
+// Error is an error returned by kamailio
+type Error struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (err *Error) Error() string {
+	return fmt.Sprintf("kamailio rpc error %d: %s", err.Code, err.Message)
+}
+
+// Client calls RPC commands of kamailio
+type Client struct {
+	Transport Transport
+
+	// Timeout for a single call, defaults to 5 seconds
+	Timeout time.Duration
+
+	lastID int64
+}
+
+// NewClient returns a client for the address, which is one of
+//   - unix:/run/kamailio/kamailio_rpc.sock
+//   - fifo:/run/kamailio/kamailio_rpc.fifo
+//   - http://127.0.0.1:5060/RPC
+func NewClient(address string) (*Client, error) {
+	var transport Transport
+
+	switch {
+	case strings.HasPrefix(address, "unix:"):
+		transport = &UnixTransport{Path: strings.TrimPrefix(address, "unix:")}
+	case strings.HasPrefix(address, "fifo:"):
+		transport = &FifoTransport{Path: strings.TrimPrefix(address, "fifo:")}
+	case strings.HasPrefix(address, "http://"), strings.HasPrefix(address, "https://"):
+		transport = &HTTPTransport{URL: address}
+	default:
+		return nil, fmt.Errorf("unsupported kamailio rpc address '%s'", address)
+	}
+
+	return &Client{Transport: transport}, nil
+}
+
+// Call calls the RPC command method, and returns the raw result
+func (client *Client) Call(ctx context.Context, method string, params ...interface{}) (json.RawMessage, error) {
+	timeout := client.Timeout
+	if timeout == 0 {
+		timeout = 5 * time.Second
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	req := request{
+		JSONRPC: "2.0",
+		Method:  method,
+		Params:  params,
+		ID:      atomic.AddInt64(&client.lastID, 1),
+	}
+
+	reqBytes, err := json.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+
+	respBytes, err := client.Transport.RoundTrip(ctx, reqBytes)
+	if err != nil {
+		return nil, fmt.Errorf("unable to call %s: %w", method, err)
+	}
+
+	resp := &response{}
+	err = json.Unmarshal(respBytes, resp)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse the response of %s: %w", method, err)
+	}
+
+	if resp.Error != nil {
+		return nil, resp.Error
+	}
+
+	return resp.Result, nil
+}
+
+// CallWithRetry calls the command until it succeeds, or retries are exhausted.
+// The delay between the attempts is doubled each time.
+func (client *Client) CallWithRetry(ctx context.Context, retries int, delay time.Duration, method string, params ...interface{}) (json.RawMessage, error) {
+	for attempt := 0; ; attempt++ {
+		result, err := client.Call(ctx, method, params...)
+		if err == nil || attempt >= retries {
+			return result, err
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, err
+		case <-time.After(delay):
+			delay *= 2
+		}
+	}
+}
+
+// ParseCommand parses a command line like 'htable.reload routes' into the method
+// and its parameters. Numeric parameters are passed as numbers.
+func ParseCommand(command string) (string, []interface{}) {
+	fields := strings.Fields(command)
+	if len(fields) == 0 {
+		return "", nil
+	}
+
+	params := []interface{}{}
+	for _, field := range fields[1:] {
+		var number json.Number
+		if err := json.Unmarshal([]byte(field), &number); err == nil {
+			params = append(params, number)
+		} else {
+			params = append(params, field)
+		}
+	}
+
+	return fields[0], params
+}
@@ -0,0 +1,151 @@
+package kamailio
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// reply returns a response for the request, failing for the method "fail"
+func reply(t *testing.T, body []byte) []byte {
+	req := &request{}
+	assert.NoError(t, json.Unmarshal(body, req))
+
+	resp := &response{JSONRPC: "2.0", ID: req.ID}
+	if req.Method == "fail" {
+		resp.Error = &Error{Code: 500, Message: "failed"}
+	} else {
+		resp.Result, _ = json.Marshal(map[string]interface{}{"method": req.Method, "params": req.Params})
+	}
+
+	bytes, _ := json.Marshal(resp)
+	return bytes
+}
+
+func TestHTTPTransport(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		w.Write(reply(t, body))
+	}))
+	defer server.Close()
+
+	client, err := NewClient(server.URL + "/RPC")
+	assert.NoError(t, err)
+
+	result, err := client.Call(context.Background(), "htable.reload", "routes")
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"method": "htable.reload", "params": ["routes"]}`, string(result))
+
+	_, err = client.Call(context.Background(), "fail")
+	assert.Error(t, err)
+	assert.IsType(t, &Error{}, err)
+}
+
+func TestUnixTransport(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "kamailio_rpc.sock")
+
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: path, Net: "unixgram"})
+	assert.NoError(t, err)
+	defer conn.Close()
+
+	go func() {
+		buffer := make([]byte, maxDatagramSize)
+		n, addr, err := conn.ReadFromUnix(buffer)
+		if err == nil {
+			conn.WriteToUnix(reply(t, buffer[:n]), addr)
+		}
+	}()
+
+	client := &Client{Transport: &UnixTransport{Path: path, ReplyDir: dir}, Timeout: time.Second}
+	result, err := client.Call(context.Background(), "dispatcher.reload")
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"method": "dispatcher.reload", "params": null}`, string(result))
+}
+
+func TestUnixTransport_ReplyDir(t *testing.T) {
+	// kamailio runs in another container, and only shares the directory of its socket
+	dir, otherDir := t.TempDir(), t.TempDir()
+	path := filepath.Join(dir, "kamailio_rpc.sock")
+
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: path, Net: "unixgram"})
+	assert.NoError(t, err)
+	defer conn.Close()
+
+	replyDirs := make(chan string, 2)
+	go func() {
+		buffer := make([]byte, maxDatagramSize)
+		for {
+			n, addr, err := conn.ReadFromUnix(buffer)
+			if err != nil {
+				return
+			}
+			replyDirs <- filepath.Dir(addr.Name)
+			conn.WriteToUnix(reply(t, buffer[:n]), addr)
+		}
+	}()
+
+	client, err := NewClient("unix:" + path)
+	assert.NoError(t, err)
+	_, err = client.Call(context.Background(), "dispatcher.reload")
+	assert.NoError(t, err)
+	assert.Equal(t, dir, <-replyDirs)
+
+	client = &Client{Transport: &UnixTransport{Path: path, ReplyDir: otherDir}, Timeout: time.Second}
+	_, err = client.Call(context.Background(), "dispatcher.reload")
+	assert.NoError(t, err)
+	assert.Equal(t, otherDir, <-replyDirs)
+}
+
+func TestFifoTransport(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "kamailio_rpc.fifo")
+	assert.NoError(t, syscall.Mkfifo(path, 0660))
+
+	// like jsonrpcs with 'fifo_reply_dir' set to the directory of the FIFO
+	go func() {
+		fifo, err := os.Open(path)
+		if err != nil {
+			return
+		}
+		defer fifo.Close()
+
+		body, _ := io.ReadAll(fifo)
+		name, request, _ := strings.Cut(strings.TrimPrefix(string(body), ":"), ":")
+		os.WriteFile(filepath.Join(dir, name), reply(t, []byte(request)), 0)
+	}()
+
+	client, err := NewClient("fifo:" + path)
+	assert.NoError(t, err)
+	client.Timeout = time.Second
+
+	result, err := client.Call(context.Background(), "htable.reload", "routes")
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"method": "htable.reload", "params": ["routes"]}`, string(result))
+}
+
+func TestNewClient_Unsupported(t *testing.T) {
+	_, err := NewClient("tcp:127.0.0.1:2046")
+	assert.Error(t, err)
+}
+
+func TestParseCommand(t *testing.T) {
+	method, params := ParseCommand("cfg.sets  core debug 3")
+	assert.Equal(t, "cfg.sets", method)
+	assert.Equal(t, []interface{}{"core", "debug", json.Number("3")}, params)
+
+	method, params = ParseCommand("app_python3.reload")
+	assert.Equal(t, "app_python3.reload", method)
+	assert.Equal(t, 0, len(params))
+}
@@ -0,0 +1,175 @@
+package kamailio
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync/atomic"
+	"syscall"
+	"time"
+)
+
+// the maximum size of a datagram response
+const maxDatagramSize = 64 * 1024
+
+var replyCounter int64
+
+// replyName returns a unique name for a reply socket or FIFO
+func replyName(prefix string) string {
+	return prefix + strconv.Itoa(os.Getpid()) + "_" + strconv.FormatInt(atomic.AddInt64(&replyCounter, 1), 10)
+}
+
+// UnixTransport uses the unix datagram socket of jsonrpcs ('dgram_socket').
+// The response is sent to a temporary socket bound in ReplyDir.
+type UnixTransport struct {
+	Path string
+
+	// ReplyDir is the directory for the reply socket, which kamailio must be able to reach.
+	// Defaults to the directory of the socket, as it is shared with kamailio anyway.
+	ReplyDir string
+}
+
+func (transport *UnixTransport) RoundTrip(ctx context.Context, request []byte) ([]byte, error) {
+	replyDir := transport.ReplyDir
+	if replyDir == "" {
+		replyDir = filepath.Dir(transport.Path)
+	}
+
+	local := &net.UnixAddr{Name: filepath.Join(replyDir, replyName("kasico_rpc_")+".sock"), Net: "unixgram"}
+	conn, err := net.ListenUnixgram("unixgram", local)
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(local.Name)
+	defer conn.Close()
+
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	_, err = conn.WriteToUnix(request, &net.UnixAddr{Name: transport.Path, Net: "unixgram"})
+	if err != nil {
+		return nil, err
+	}
+
+	buffer := make([]byte, maxDatagramSize)
+	n, _, err := conn.ReadFromUnix(buffer)
+	if err != nil {
+		return nil, err
+	}
+
+	return buffer[:n], nil
+}
+
+// FifoTransport uses the FIFO of jsonrpcs ('fifo_name').
+// The request is prefixed with the name of the reply FIFO, which is created
+// in ReplyDir. This has to match the 'fifo_reply_dir' of jsonrpcs.
+type FifoTransport struct {
+	Path string
+
+	// ReplyDir defaults to the directory of the FIFO, as it is shared with kamailio,
+	// so 'fifo_reply_dir' has to be set to it
+	ReplyDir string
+}
+
+func (transport *FifoTransport) RoundTrip(ctx context.Context, request []byte) ([]byte, error) {
+	replyDir := transport.ReplyDir
+	if replyDir == "" {
+		replyDir = filepath.Dir(transport.Path)
+	}
+
+	name := replyName("kasico_rpc_reply_") + ".fifo"
+	replyPath := filepath.Join(replyDir, name)
+
+	err := syscall.Mkfifo(replyPath, 0660)
+	if err != nil {
+		return nil, fmt.Errorf("unable to create the reply fifo: %w", err)
+	}
+	defer os.Remove(replyPath)
+
+	fifo, err := os.OpenFile(transport.Path, os.O_WRONLY, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = fifo.Write(append([]byte(":"+name+":"), request...))
+	fifo.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	// opening a FIFO for reading blocks until kamailio opens it for writing,
+	// so this is done in the background to respect the deadline of the context
+	type result struct {
+		response []byte
+		err      error
+	}
+
+	done := make(chan result, 1)
+	go func() {
+		reply, err := os.Open(replyPath)
+		if err != nil {
+			done <- result{err: err}
+			return
+		}
+		defer reply.Close()
+
+		response, err := io.ReadAll(reply)
+		done <- result{response: response, err: err}
+	}()
+
+	select {
+	case r := <-done:
+		return r.response, r.err
+	case <-ctx.Done():
+		// unblock the reader, by opening the FIFO for writing
+		if w, err := os.OpenFile(replyPath, os.O_WRONLY|syscall.O_NONBLOCK, 0); err == nil {
+			w.Close()
+		}
+		return nil, ctx.Err()
+	}
+}
+
+// HTTPTransport posts the request to the xhttp endpoint of jsonrpcs
+type HTTPTransport struct {
+	URL    string
+	Client *http.Client
+}
+
+func (transport *HTTPTransport) RoundTrip(ctx context.Context, request []byte) ([]byte, error) {
+	client := transport.Client
+	if client == nil {
+		client = &http.Client{Timeout: 30 * time.Second}
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, transport.URL, bytes.NewReader(request))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	// jsonrpcs returns errors with a JSON body and a non 200 status, so we
+	// only fail if there is no body to parse
+	if resp.StatusCode != http.StatusOK && len(body) == 0 {
+		return nil, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+
+	return body, nil
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/spf13/cobra"
+
+	"github.com/world-direct/kasico/operator/lint"
+	"github.com/world-direct/kasico/operator/manifests"
+)
+
+type lintOptions struct {
+	filenames []string
+	output    string
+	strict    bool
+	lint      lint.Options
+}
+
+func newLintCommand() *cobra.Command {
+	var opts lintOptions
+	lintCmd := &cobra.Command{
+		Use:   "lint [files or directories...]",
+		Short: "Validates Ingress and RouterInstance manifests",
+		Long: `Validates the Ingresses, RouterInstances, template ConfigMaps, Secrets and Services in the
+manifests, without a cluster. The exit code is 1 if errors are found. Manifests without namespace
+are in the --namespace.
+
+Checks: ` + lint.CheckNumberFormat + `, ` + lint.CheckDomainFormat + `, ` + lint.CheckEmptyRule + `, ` + lint.CheckDuplicateRule + `,
+` + lint.CheckOverlappingRule + `, ` + lint.CheckUnknownClass + `, ` + lint.CheckMissingService + `, ` + lint.CheckMissingTemplate + `,
+` + lint.CheckMissingSecret + `, ` + lint.CheckTemplate,
+		Example: `  kasico lint manifests/
+  kasico lint -o json --skip missing-service ingresses/`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return main_lint(cmd.OutOrStdout(), opts, args)
+		},
+	}
+
+	lintCmd.Flags().StringSliceVarP(&opts.filenames, "filename", "f", nil, "The files or directories containing the manifests, in addition to the arguments")
+	lintCmd.Flags().StringVarP(&opts.output, "output", "o", "text", "The output format: text or json")
+	lintCmd.Flags().BoolVar(&opts.strict, "strict", false, "Warnings fail as well")
+	lintCmd.Flags().StringSliceVar(&opts.lint.Skip, "skip", nil, "The checks to skip")
+
+	return lintCmd
+}
+
+// lintResult is the JSON output of the lint command
+type lintResult struct {
+	Findings []lint.Finding `json:"findings"`
+	Errors   int            `json:"errors"`
+	Warnings int            `json:"warnings"`
+}
+
+func main_lint(out io.Writer, opts lintOptions, args []string) error {
+	paths := append(opts.filenames, args...)
+	if len(paths) == 0 {
+		return fmt.Errorf("no manifests given")
+	}
+
+	namespace, err := currentNamespace()
+	if err != nil {
+		return err
+	}
+
+	set, err := manifests.Load(namespace, paths...)
+	if err != nil {
+		return err
+	}
+
+	findings := lint.Lint(set, opts.lint)
+	result := lintResult{Findings: findings, Errors: lint.Errors(findings)}
+	result.Warnings = len(findings) - result.Errors
+	if result.Findings == nil {
+		result.Findings = []lint.Finding{}
+	}
+
+	switch opts.output {
+	case "json":
+		err = printJSON(out, result)
+		if err != nil {
+			return err
+		}
+	case "text":
+		for _, finding := range findings {
+			fmt.Fprintln(out, finding)
+		}
+		fmt.Fprintf(out, "%d Ingresses, %d RouterInstances: %d errors, %d warnings\n",
+			len(set.Ingresses), len(set.RouterInstances), result.Errors, result.Warnings)
+	default:
+		return fmt.Errorf("unknown output format %s", opts.output)
+	}
+
+	if result.Errors > 0 || opts.strict && result.Warnings > 0 {
+		return fmt.Errorf("the manifests have %d errors and %d warnings", result.Errors, result.Warnings)
+	}
+
+	return nil
+}
@@ -0,0 +1,337 @@
+// Package lint validates Ingress and RouterInstance manifests offline, before they are applied.
+package lint
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	kasicov1 "github.com/world-direct/kasico/operator/api/v1"
+	"github.com/world-direct/kasico/operator/controllers"
+	"github.com/world-direct/kasico/operator/manifests"
+	"github.com/world-direct/kasico/operator/sidecar"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+type Severity string
+
+const (
+	SeverityError   Severity = "error"
+	SeverityWarning Severity = "warning"
+)
+
+// The names of the checks, which can be skipped
+const (
+	CheckNumberFormat    = "number-format"
+	CheckDomainFormat    = "domain-format"
+	CheckEmptyRule       = "empty-rule"
+	CheckDuplicateRule   = "duplicate-rule"
+	CheckOverlappingRule = "overlapping-rule"
+	CheckUnknownClass    = "unknown-class"
+	CheckMissingService  = "missing-service"
+	CheckMissingTemplate = "missing-template"
+	CheckMissingSecret   = "missing-secret"
+	CheckTemplate        = "template"
+)
+
+// Finding is a problem found in the manifests
+type Finding struct {
+	Severity Severity `json:"severity"`
+	Check    string   `json:"check"`
+
+	// Object is 'Kind namespace/name'
+	Object  string `json:"object"`
+	Field   string `json:"field,omitempty"`
+	Message string `json:"message"`
+
+	// Related is the other object of a conflict, 'Kind namespace/name'
+	Related string `json:"related,omitempty"`
+}
+
+func (finding Finding) String() string {
+	field := ""
+	if finding.Field != "" {
+		field = " " + finding.Field
+	}
+
+	return fmt.Sprintf("%-7s %s%s: %s (%s)", finding.Severity, finding.Object, field, finding.Message, finding.Check)
+}
+
+// Options configures Lint
+type Options struct {
+	// Skip are the names of the checks which are not run
+	Skip []string
+}
+
+// headnumbers are international numbers, or prefixes of them
+var headnumberPattern = regexp.MustCompile(`^\+[1-9][0-9]{0,14}$`)
+
+type linter struct {
+	set      *manifests.Set
+	skip     map[string]bool
+	findings []Finding
+}
+
+// Lint checks the manifests, and returns the findings sorted by object and field
+func Lint(set *manifests.Set, options Options) []Finding {
+	l := &linter{set: set, skip: map[string]bool{}}
+	for _, check := range options.Skip {
+		l.skip[check] = true
+	}
+
+	l.lintRules()
+	l.lintConflicts()
+	l.lintRouterInstances()
+
+	sort.SliceStable(l.findings, func(i, j int) bool {
+		a, b := &l.findings[i], &l.findings[j]
+		if a.Object != b.Object {
+			return a.Object < b.Object
+		}
+		return a.Field < b.Field
+	})
+
+	return l.findings
+}
+
+// Errors returns the number of findings with SeverityError
+func Errors(findings []Finding) int {
+	errors := 0
+	for _, finding := range findings {
+		if finding.Severity == SeverityError {
+			errors++
+		}
+	}
+
+	return errors
+}
+
+func (l *linter) report(severity Severity, check string, object string, field string, format string, args ...interface{}) {
+	l.reportRelated(severity, check, object, field, "", format, args...)
+}
+
+func (l *linter) reportRelated(severity Severity, check string, object string, field string, related string, format string, args ...interface{}) {
+	if l.skip[check] {
+		return
+	}
+
+	l.findings = append(l.findings, Finding{
+		Severity: severity,
+		Check:    check,
+		Object:   object,
+		Field:    field,
+		Message:  fmt.Sprintf(format, args...),
+		Related:  related,
+	})
+}
+
+func ingressObject(ingress *kasicov1.Ingress) string {
+	return "Ingress " + ingress.Namespace + "/" + ingress.Name
+}
+
+func routerObject(router *kasicov1.RouterInstance) string {
+	return "RouterInstance " + router.Namespace + "/" + router.Name
+}
+
+// lintRules checks the fields of every rule on its own
+func (l *linter) lintRules() {
+	classes := map[string]bool{}
+	for _, router := range l.set.RouterInstances {
+		classes[router.Spec.IngressClassName] = true
+	}
+
+	for i := range l.set.Ingresses {
+		ingress := &l.set.Ingresses[i]
+		object := ingressObject(ingress)
+
+		// without RouterInstances in the manifests, the classes are not known
+		if len(classes) > 0 && !classes[ingress.Spec.IngressClassName] {
+			l.report(SeverityWarning, CheckUnknownClass, object, "spec.ingressClassName",
+				"no RouterInstance serves the class '%s', the Ingress is not used", ingress.Spec.IngressClassName)
+		}
+
+		for j, rule := range ingress.Spec.Rules {
+			field := fmt.Sprintf("spec.rules[%d]", j)
+
+			if rule.Sip.Domain == "" && rule.Sip.Headnumber == "" {
+				l.report(SeverityError, CheckEmptyRule, object, field+".sip", "neither domain nor headnumber is set, the rule never matches")
+			}
+
+			if rule.Sip.Headnumber != "" && !headnumberPattern.MatchString(rule.Sip.Headnumber) {
+				l.report(SeverityError, CheckNumberFormat, object, field+".sip.headnumber",
+					"'%s' is not an international number like +43512334455", rule.Sip.Headnumber)
+			}
+
+			if rule.Sip.Domain != "" {
+				for _, message := range validation.IsDNS1123Subdomain(rule.Sip.Domain) {
+					l.report(SeverityError, CheckDomainFormat, object, field+".sip.domain", "'%s' is not a valid domain: %s", rule.Sip.Domain, message)
+				}
+			}
+
+			service := rule.Backend.Service.Name
+			if service == "" {
+				l.report(SeverityError, CheckMissingService, object, field+".backend.service.name", "no backend Service is set")
+			} else if !l.hasService(ingress.Namespace, service) {
+				l.report(SeverityError, CheckMissingService, object, field+".backend.service.name",
+					"the Service %s/%s is not in the manifests, the rule is dropped", ingress.Namespace, service)
+			}
+		}
+	}
+}
+
+func (l *linter) hasService(namespace string, name string) bool {
+	for _, service := range l.set.Services {
+		if service.Namespace == namespace && service.Name == name {
+			return true
+		}
+	}
+
+	return false
+}
+
+// lintConflicts checks the rules of all Ingresses of the same class against each other
+func (l *linter) lintConflicts() {
+	byClass := map[string][]controllers.RoutingRule{}
+	for _, ingress := range l.set.Ingresses {
+		if controllers.IsDryRun(&ingress) {
+			continue
+		}
+
+		// without backend lookup, so the missing Services are not reported twice
+		rd := controllers.GetRoutingData(kasicov1.RouterInstance{Spec: kasicov1.RouterInstanceSpec{IngressClassName: ingress.Spec.IngressClassName}},
+			[]kasicov1.Ingress{ingress}, nil)
+		byClass[ingress.Spec.IngressClassName] = append(byClass[ingress.Spec.IngressClassName], rd.Rules...)
+	}
+
+	for _, rules := range byClass {
+		controllers.SortRoutingRules(rules)
+
+		// the domains and headnumbers are resolved separately, like in the routers
+		entries, shadowed := controllers.ResolveRoutes(rules)
+		for _, shadow := range shadowed {
+			severity := SeverityError
+			if shadow.Rule.Owner == shadow.By.Owner {
+				severity = SeverityWarning
+			}
+
+			l.reportRelated(severity, CheckDuplicateRule, "Ingress "+shadow.Rule.Owner, "", "Ingress "+shadow.By.Owner,
+				"the %s %s of the rule '%s' is routed to %s of %s", shadow.MatchType, shadow.Key, ruleMatch(&shadow.Rule), shadow.By.Backend, shadow.By.Owner)
+		}
+
+		// a longer headnumber of another Ingress takes over a part of the number block
+		for i := range entries {
+			for j := range entries {
+				a, b := &entries[i], &entries[j]
+				if a.MatchType != controllers.MatchTypeHeadnumber || b.MatchType != controllers.MatchTypeHeadnumber ||
+					a.Rule.Owner == b.Rule.Owner || a.Key == b.Key || !controllers.HeadnumberMatches(b.Key, a.Key) {
+					continue
+				}
+
+				l.reportRelated(SeverityWarning, CheckOverlappingRule, "Ingress "+b.Rule.Owner, "", "Ingress "+a.Rule.Owner,
+					"the numbers of '%s' starting with %s are routed to %s of %s", ruleMatch(&b.Rule), a.Key, a.Rule.Backend, a.Rule.Owner)
+			}
+		}
+	}
+}
+
+func ruleMatch(rule *controllers.RoutingRule) string {
+	return strings.TrimSpace(rule.Domain + " " + rule.Headnumber)
+}
+
+// lintRouterInstances checks the template ConfigMaps and Secrets of every RouterInstance
+func (l *linter) lintRouterInstances() {
+	for i := range l.set.RouterInstances {
+		router := &l.set.RouterInstances[i]
+		object := routerObject(router)
+
+		layers := []map[string]string{}
+		for _, name := range router.Spec.TemplateSources() {
+			cm := l.configMap(router.Namespace, name)
+			if cm == nil {
+				l.report(SeverityWarning, CheckMissingTemplate, object, "spec", "the template ConfigMap %s is not in the manifests", name)
+				continue
+			}
+
+			templates, err := sidecar.TemplateData(cm)
+			if err != nil {
+				l.report(SeverityError, CheckTemplate, object, "spec", "%v", err)
+				continue
+			}
+			layers = append(layers, templates)
+		}
+
+		secrets := []map[string]string{}
+		for _, name := range router.Spec.TemplateSecrets {
+			secret := l.secret(router.Namespace, name)
+			if secret == nil {
+				l.report(SeverityWarning, CheckMissingSecret, object, "spec.templateSecrets", "the Secret %s is not in the manifests", name)
+				continue
+			}
+			secrets = append(secrets, secret)
+		}
+
+		if len(layers) == 0 {
+			continue
+		}
+
+		l.lintTemplates(router, sidecar.MergeLayers(layers...), sidecar.MergeLayers(secrets...))
+	}
+}
+
+// lintTemplates renders the templates with the routing-data of the manifests, and with
+// a routing-data using every field, so templates referencing missing fields are found,
+// even if the manifests contain no rules.
+func (l *linter) lintTemplates(router *kasicov1.RouterInstance, templates map[string]string, secrets map[string]string) {
+	example := &controllers.RoutingData{
+		UDPPort:          5060,
+		TCPPort:          5060,
+		AdvertiseAddress: "192.0.2.1",
+		Generation:       1,
+		Rules: []controllers.RoutingRule{
+			{Domain: "sip.example.com", Headnumber: "+43512334455", Owner: "default/example", Backend: "pbx.default", Endpoints: []string{"192.0.2.10:5060"}},
+			{Headnumber: "+4351233", Owner: "default/example", Backend: "pbx.default"},
+		},
+	}
+
+	seen := map[string]bool{}
+	for _, rd := range []*controllers.RoutingData{controllers.GetRoutingData(*router, l.set.Ingresses, l.set.Backends()), example} {
+		json, err := controllers.MarshalRoutingData(rd)
+		if err != nil {
+			continue
+		}
+
+		// the sidecar may allow exec and http actions, which is not known here
+		_, err = sidecar.Render(&sidecar.Inputs{Templates: templates, RoutingData: json, Secrets: secrets}, true)
+		if err != nil && !seen[err.Error()] {
+			seen[err.Error()] = true
+			l.report(SeverityError, CheckTemplate, routerObject(router), "", "%v", err)
+		}
+	}
+}
+
+func (l *linter) configMap(namespace string, name string) *corev1.ConfigMap {
+	for i := range l.set.ConfigMaps {
+		cm := &l.set.ConfigMaps[i]
+		if cm.Namespace == namespace && cm.Name == name {
+			return cm
+		}
+	}
+
+	return nil
+}
+
+func (l *linter) secret(namespace string, name string) map[string]string {
+	for _, secret := range l.set.Secrets {
+		if secret.Namespace == namespace && secret.Name == name {
+			values := map[string]string{}
+			for key, value := range secret.Data {
+				values[key] = string(value)
+			}
+			return values
+		}
+	}
+
+	return nil
+}
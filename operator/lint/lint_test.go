@@ -0,0 +1,186 @@
+package lint
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/world-direct/kasico/operator/manifests"
+)
+
+const testManifests = `
+apiVersion: kasico.world-direct.at/v1
+kind: RouterInstance
+metadata:
+  name: router
+spec:
+  ingressClassName: default
+  templateConfigMapName: templates
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: templates
+data:
+  routes.cfg: |
+    {{range .Rules}}{{.Headnumber}} {{.Backnd}}{{end}}
+---
+apiVersion: v1
+kind: Service
+metadata:
+  name: pbx
+---
+apiVersion: kasico.world-direct.at/v1
+kind: Ingress
+metadata:
+  name: a
+spec:
+  ingressClassName: default
+  rules:
+  - sip:
+      headnumber: "+43512"
+    backend:
+      service:
+        name: pbx
+---
+apiVersion: kasico.world-direct.at/v1
+kind: Ingress
+metadata:
+  name: b
+spec:
+  ingressClassName: default
+  rules:
+  - sip:
+      headnumber: "+43512"
+    backend:
+      service:
+        name: pbx
+  - sip:
+      headnumber: "+435123344"
+    backend:
+      service:
+        name: pbx
+`
+
+func checks(findings []Finding) []string {
+	result := []string{}
+	for _, finding := range findings {
+		result = append(result, string(finding.Severity)+" "+finding.Check+" "+finding.Object)
+	}
+
+	return result
+}
+
+func TestLint(t *testing.T) {
+	set := &manifests.Set{}
+	assert.NoError(t, set.Add("default", strings.NewReader(testManifests)))
+
+	findings := Lint(set, Options{})
+	assert.Equal(t, []string{
+		"warning overlapping-rule Ingress default/a",
+		"error duplicate-rule Ingress default/b",
+		"error template RouterInstance default/router",
+	}, checks(findings))
+	assert.Equal(t, "Ingress default/a", findings[1].Related)
+	assert.Contains(t, findings[2].Message, "Backnd")
+	assert.Equal(t, 2, Errors(findings))
+
+	findings = Lint(set, Options{Skip: []string{CheckTemplate, CheckDuplicateRule}})
+	assert.Equal(t, []string{"warning overlapping-rule Ingress default/a"}, checks(findings))
+}
+
+func TestLint_Overlap(t *testing.T) {
+	set := &manifests.Set{}
+	assert.NoError(t, set.Add("default", strings.NewReader(`
+apiVersion: v1
+kind: Service
+metadata:
+  name: pbx
+---
+apiVersion: kasico.world-direct.at/v1
+kind: Ingress
+metadata:
+  name: a
+spec:
+  ingressClassName: default
+  rules:
+  - sip:
+      domain: a.example.com
+      headnumber: "+43512"
+    backend:
+      service:
+        name: pbx
+---
+apiVersion: kasico.world-direct.at/v1
+kind: Ingress
+metadata:
+  name: b
+spec:
+  ingressClassName: default
+  rules:
+  - sip:
+      headnumber: "+435123344"
+    backend:
+      service:
+        name: pbx
+`)))
+
+	// the headnumbers are looked up independent of the domain, like in the routers
+	findings := Lint(set, Options{})
+	assert.Equal(t, []string{"warning overlapping-rule Ingress default/a"}, checks(findings))
+	assert.Equal(t, "Ingress default/b", findings[0].Related)
+}
+
+func TestLint_Conflicts(t *testing.T) {
+	set := &manifests.Set{}
+	assert.NoError(t, set.Add("default", strings.NewReader(`
+apiVersion: v1
+kind: Service
+metadata:
+  name: pbx
+---
+apiVersion: kasico.world-direct.at/v1
+kind: Ingress
+metadata:
+  name: a
+spec:
+  ingressClassName: default
+  rules:
+  - sip:
+      domain: a.example.com
+      headnumber: "+43512"
+    backend:
+      service:
+        name: pbx
+---
+apiVersion: kasico.world-direct.at/v1
+kind: Ingress
+metadata:
+  name: b
+spec:
+  ingressClassName: default
+  rules:
+  - sip:
+      domain: b.example.com
+      headnumber: "+43512"
+    backend:
+      service:
+        name: pbx
+  - sip:
+      domain: a.example.com
+      headnumber: "+43513"
+    backend:
+      service:
+        name: pbx
+`)))
+
+	// the routers only use the first rule of a headnumber, or a domain, independent of the other
+	findings := Lint(set, Options{})
+	assert.Equal(t, []string{
+		"error duplicate-rule Ingress default/b",
+		"error duplicate-rule Ingress default/b",
+	}, checks(findings))
+	assert.Equal(t, "Ingress default/a", findings[0].Related)
+	assert.Contains(t, findings[0].Message, "the domain a.example.com")
+	assert.Contains(t, findings[1].Message, "the headnumber +43512")
+}
@@ -17,27 +17,25 @@ limitations under the License.
 package main
 
 import (
-	"flag"
-	"fmt"
 	"os"
-	"time"
+	"path/filepath"
+	"strings"
 
 	// Import all Kubernetes client auth plugins (e.g. Azure, GCP, OIDC, etc.)
 	// to ensure that exec-entrypoint and run can make use of them.
 	"go.uber.org/zap/zapcore"
 	_ "k8s.io/client-go/plugin/pkg/client/auth"
-	"k8s.io/client-go/tools/cache"
 
 	"k8s.io/apimachinery/pkg/runtime"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
 	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/rest"
 	ctrl "sigs.k8s.io/controller-runtime"
-	"sigs.k8s.io/controller-runtime/pkg/healthz"
 	"sigs.k8s.io/controller-runtime/pkg/log/zap"
 
+	configv1alpha1 "github.com/world-direct/kasico/operator/api/config/v1alpha1"
 	kasicov1 "github.com/world-direct/kasico/operator/api/v1"
-	"github.com/world-direct/kasico/operator/controllers"
-	corev1 "k8s.io/api/core/v1"
 
 	"github.com/spf13/cobra"
 	//+kubebuilder:scaffold:imports
@@ -46,12 +44,16 @@ import (
 var (
 	scheme   = runtime.NewScheme()
 	setupLog = ctrl.Log.WithName("setup")
+
+	// configFlags are the flags of kubectl, so the commands use the kubeconfig, context and namespace like kubectl
+	configFlags = genericclioptions.NewConfigFlags(true)
 )
 
 func init() {
 	utilruntime.Must(clientgoscheme.AddToScheme(scheme))
 
 	utilruntime.Must(kasicov1.AddToScheme(scheme))
+	utilruntime.Must(configv1alpha1.AddToScheme(scheme))
 	//+kubebuilder:scaffold:scheme
 }
 
@@ -62,172 +64,113 @@ func main() {
 	rootCmd := &cobra.Command{
 		Use:   "kasico",
 		Short: "Kasico stands for is KAmailio Sip Ingress COntroller",
+		// errors of the commands are not caused by wrong usage
+		SilenceUsage: true,
+		// the flags are parsed before, so all commands share the logging setup
+		PersistentPreRun: func(cmd *cobra.Command, args []string) {
+			setupLogging(argDevelopment)
+		},
 	}
 
-	// this flag is registered directly to the commandline flags by the controller-runtime
-	// https://github.com/kubernetes-sigs/controller-runtime/blob/master/pkg/client/config/config.go#L39
-	// we don't do anything with this flag here, it's just so that cobra knows about it, and generated
-	// correct documentation with --help, or completion
-	_ = rootCmd.Flags().String("kubeconfig", "", "Paths to a kubeconfig. Only required if out-of-cluster.")
-	rootCmd.Flags().BoolVar(&argDevelopment, "development", false, "Enables development mode incl verbose logging")
-
-	operatorCmd := &cobra.Command{
-		Use:   "operator",
-		Short: "Runs the kasico operator",
-	}
+	// --namespace is the namespace of the CLI commands, and the namespace watched by the operator and controller
+	configFlags.AddFlags(rootCmd.PersistentFlags())
+	rootCmd.PersistentFlags().BoolVar(&argDevelopment, "development", false, "Enables development mode incl verbose logging")
 
 	controllerCmd := &cobra.Command{
 		Use:   "controller",
 		Short: "Runs the kasico controller",
 	}
 
-	controllerWatchCmd := &cobra.Command{
-		Use:   "watch",
-		Short: "Runs the kasico controller in watch mode",
-	}
-
-	controllerGenerateCmd := &cobra.Command{
-		Use:   "generate",
-		Short: "Runs the kasico controller in generation mode",
-	}
-
-	controllerCmd.AddCommand(controllerWatchCmd)
-	controllerCmd.AddCommand(controllerGenerateCmd)
+	controllerCmd.AddCommand(newControllerWatchCommand())
+	controllerCmd.AddCommand(newControllerGenerateCommand())
 
-	rootCmd.AddCommand(operatorCmd)
+	rootCmd.AddCommand(newOperatorCommand())
 	rootCmd.AddCommand(controllerCmd)
+	rootCmd.AddCommand(newRenderCommand())
+	rootCmd.AddCommand(newRouteCommand())
+	rootCmd.AddCommand(newLintCommand())
+	rootCmd.AddCommand(newDiffCommand())
+	rootCmd.AddCommand(newStatusCommand())
+	rootCmd.AddCommand(newImportCommand())
 
-	rootCmd.Execute()
-
-	var metricsAddr string
-	var enableLeaderElection bool
-	var probeAddr string
-	var mode string
-
-	flag.StringVar(&metricsAddr, "metrics-bind-address", ":8080", "The address the metric endpoint binds to.")
-	flag.StringVar(&probeAddr, "health-probe-bind-address", ":8081", "The address the probe endpoint binds to.")
-	flag.BoolVar(&enableLeaderElection, "leader-elect", false, "Enable leader election for controller manager. ")
-
-	opts := zap.Options{
-		Development: argDevelopment,
-		TimeEncoder: zapcore.RFC3339TimeEncoder,
+	if isKubectlPlugin(os.Args[0]) {
+		setupKubectlPlugin(rootCmd)
 	}
 
-	if argDevelopment {
-		opts.Level = zapcore.Level(-2)
+	if err := rootCmd.Execute(); err != nil {
+		os.Exit(1)
 	}
+}
 
-	// we really don't want to provide all these options, as they don't bring really value
-	// we will reduce this to a general "debug" argument
-	// opts.BindFlags(flag.CommandLine)	// this is also not very compatible to cobra
-	flag.Parse()
-
-	ctrl.SetLogger(zap.New(zap.UseFlagOptions(&opts)))
+// isKubectlPlugin returns true, if the binary is installed as kubectl plugin, and invoked by 'kubectl kasico'
+func isKubectlPlugin(binary string) bool {
+	return strings.HasPrefix(filepath.Base(binary), "kubectl-")
+}
 
-	if mode != "operator" {
-		enableLeaderElection = false
-	}
+// setupKubectlPlugin names the commands like kubectl does, and hides the commands running in the cluster
+func setupKubectlPlugin(rootCmd *cobra.Command) {
+	rootCmd.Use = "kubectl-kasico"
 
-	mgr, err := ctrl.NewManager(ctrl.GetConfigOrDie(), ctrl.Options{
-		Scheme:                 scheme,
-		MetricsBindAddress:     metricsAddr,
-		Port:                   9443,
-		HealthProbeBindAddress: probeAddr,
-		LeaderElection:         enableLeaderElection,
-		LeaderElectionID:       "kasico-lock.world-direct.at",
-		// LeaderElectionReleaseOnCancel defines if the leader should step down voluntarily
-		// when the Manager ends. This requires the binary to immediately end when the
-		// Manager is stopped, otherwise, this setting is unsafe. Setting this significantly
-		// speeds up voluntary leader transitions as the new leader don't have to wait
-		// LeaseDuration time first.
-		//
-		// In the default scaffold provided, the program ends immediately after
-		// the manager stops, so would be fine to enable this option. However,
-		// if you are doing or is intended to do any operation such as perform cleanups
-		// after the manager stops then its usage might be unsafe.
-		// LeaderElectionReleaseOnCancel: true,
-	})
-	if err != nil {
-		setupLog.Error(err, "unable to start manager")
-		os.Exit(1)
+	var walk func(cmd *cobra.Command)
+	walk = func(cmd *cobra.Command) {
+		cmd.Example = strings.ReplaceAll(cmd.Example, "  kasico ", "  kubectl kasico ")
+		for _, child := range cmd.Commands() {
+			walk(child)
+		}
 	}
+	walk(rootCmd)
 
-	switch mode {
-	case "operator":
-		main_operator(mgr)
-
-	case "watcher":
-		main_watcher(mgr)
+	for _, cmd := range rootCmd.Commands() {
+		if cmd.Name() == "operator" || cmd.Name() == "controller" {
+			cmd.Hidden = true
+		}
 	}
 }
 
-func main_generator(mgr ctrl.Manager) {
-	setupLog.Info("Starting Kasico Generator")
+// getConfigOrDie returns the config of the kubeconfig flags, which is the in-cluster config
+// in the pods, and uses the same rate limits as ctrl.GetConfigOrDie
+func getConfigOrDie() *rest.Config {
+	config, err := restConfig()
+	if err != nil {
+		setupLog.Error(err, "unable to get kubeconfig")
+		os.Exit(1)
+	}
 
+	return config
 }
 
-func main_watcher(mgr ctrl.Manager) {
-	setupLog.Info("Starting Kasico Watcher")
-	ctx := ctrl.SetupSignalHandler()
-
-	informer, err := mgr.GetCache().GetInformer(ctx, &corev1.ConfigMap{})
+func restConfig() (*rest.Config, error) {
+	config, err := configFlags.ToRESTConfig()
 	if err != nil {
-		setupLog.Error(err, "Unable to get informer")
+		return nil, err
 	}
 
-	onChange := func(obj *corev1.ConfigMap, changeType string) {
-		fmt.Printf("onChange (%s): %v\n", changeType, obj.Name)
+	if config.QPS == 0 {
+		config.QPS = 20
+		config.Burst = 30
 	}
 
-	informer.AddEventHandler(cache.ResourceEventHandlerFuncs{
-		AddFunc:    func(obj interface{}) {},
-		UpdateFunc: func(_ interface{}, newObj interface{}) { onChange(newObj.(*corev1.ConfigMap), "update") },
-		DeleteFunc: func(obj interface{}) {},
-	})
-
-	mgr.Start(ctx)
-
+	return config, nil
 }
 
-func main_operator(mgr ctrl.Manager) {
-
-	var err error
-
-	genenerator := controllers.NewGenerator(mgr.GetClient(), time.Second*5)
-
-	if err = (&controllers.RouterInstanceReconciler{
-		Client:    mgr.GetClient(),
-		Scheme:    mgr.GetScheme(),
-		Generator: genenerator,
-	}).SetupWithManager(mgr); err != nil {
-		setupLog.Error(err, "unable to create controller", "controller", "RouterInstance")
-		os.Exit(1)
-	}
+// currentNamespace returns the --namespace flag, or the namespace of the kubeconfig context, default if none
+func currentNamespace() (string, error) {
+	namespace, _, err := configFlags.ToRawKubeConfigLoader().Namespace()
+	return namespace, err
+}
 
-	if err = (&controllers.IngressReconciler{
-		Client:    mgr.GetClient(),
-		Scheme:    mgr.GetScheme(),
-		Generator: genenerator,
-	}).SetupWithManager(mgr); err != nil {
-		setupLog.Error(err, "unable to create controller", "controller", "Ingress")
-		os.Exit(1)
+func setupLogging(development bool) {
+	opts := zap.Options{
+		Development: development,
+		TimeEncoder: zapcore.RFC3339TimeEncoder,
 	}
-	//+kubebuilder:scaffold:builder
 
-	if err := mgr.AddHealthzCheck("healthz", healthz.Ping); err != nil {
-		setupLog.Error(err, "unable to set up health check")
-		os.Exit(1)
-	}
-	if err := mgr.AddReadyzCheck("readyz", healthz.Ping); err != nil {
-		setupLog.Error(err, "unable to set up ready check")
-		os.Exit(1)
+	if development {
+		opts.Level = zapcore.Level(-2)
 	}
 
-	mgr.Add(genenerator)
-
-	setupLog.Info("starting manager")
-	if err := mgr.Start(ctrl.SetupSignalHandler()); err != nil {
-		setupLog.Error(err, "problem running manager")
-		os.Exit(1)
-	}
+	// we really don't want to provide all these options, as they don't bring really value
+	// we will reduce this to a general "debug" argument
+	// opts.BindFlags(flag.CommandLine)	// this is also not very compatible to cobra
+	ctrl.SetLogger(zap.New(zap.UseFlagOptions(&opts)))
 }
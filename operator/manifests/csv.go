@@ -0,0 +1,155 @@
+package manifests
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strings"
+
+	kasicov1 "github.com/world-direct/kasico/operator/api/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+// The columns of the CSV files read by ReadIngressCSV, named in the header row
+const (
+	ColumnDomain     = "domain"
+	ColumnHeadnumber = "headnumber"
+	ColumnService    = "service"
+	ColumnNamespace  = "namespace"
+	ColumnClass      = "class"
+	ColumnTenant     = "tenant"
+)
+
+// CSVOptions configures ReadIngressCSV
+type CSVOptions struct {
+	// Namespace is the namespace of rows without namespace
+	Namespace string
+
+	// IngressClassName is the class of rows without class
+	IngressClassName string
+}
+
+// CSVResult are the Ingresses read from a CSV file
+type CSVResult struct {
+	Ingresses []kasicov1.Ingress
+
+	// Rows is the number of rows read, without the header
+	Rows int
+
+	// Duplicates is the number of rows dropped, as the tenant already has the same rule
+	Duplicates int
+}
+
+// ReadIngressCSV reads the rules of a CSV file with a header row, and groups them into an Ingress
+// per namespace and tenant, which defaults to the backend service. The Ingress is named like the tenant.
+// Rows with the same domain, headnumber and service as a previous row of the tenant are dropped.
+// Lines starting with '#' are comments.
+func ReadIngressCSV(reader io.Reader, options CSVOptions) (*CSVResult, error) {
+	r := csv.NewReader(reader)
+	r.Comment = '#'
+	r.TrimLeadingSpace = true
+
+	header, err := r.Read()
+	if err == io.EOF {
+		return nil, fmt.Errorf("the CSV file is empty")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	columns := map[string]int{}
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+
+	if _, ok := columns[ColumnService]; !ok {
+		return nil, fmt.Errorf("the column '%s' is missing in the header", ColumnService)
+	}
+
+	_, hasDomain := columns[ColumnDomain]
+	_, hasHeadnumber := columns[ColumnHeadnumber]
+	if !hasDomain && !hasHeadnumber {
+		return nil, fmt.Errorf("the column '%s' or '%s' is missing in the header", ColumnDomain, ColumnHeadnumber)
+	}
+
+	result := &CSVResult{}
+	ingresses := map[string]*kasicov1.Ingress{}
+	order := []string{}
+	rules := map[string]bool{}
+
+	for {
+		record, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		line, _ := r.FieldPos(0)
+		value := func(column string, defaultValue string) string {
+			i, ok := columns[column]
+			if !ok || i >= len(record) || strings.TrimSpace(record[i]) == "" {
+				return defaultValue
+			}
+			return strings.TrimSpace(record[i])
+		}
+
+		result.Rows++
+		rule := kasicov1.IngressRule{
+			Sip: kasicov1.IngressRuleSip{
+				Domain:     value(ColumnDomain, ""),
+				Headnumber: value(ColumnHeadnumber, ""),
+			},
+			Backend: kasicov1.IngressBackend{
+				Service: kasicov1.IngressBackendService{Name: value(ColumnService, "")},
+			},
+		}
+
+		if rule.Backend.Service.Name == "" {
+			return nil, fmt.Errorf("line %d: the %s is missing", line, ColumnService)
+		}
+
+		if rule.Sip.Domain == "" && rule.Sip.Headnumber == "" {
+			return nil, fmt.Errorf("line %d: neither %s nor %s is set", line, ColumnDomain, ColumnHeadnumber)
+		}
+
+		namespace := value(ColumnNamespace, options.Namespace)
+		class := value(ColumnClass, options.IngressClassName)
+		tenant := value(ColumnTenant, rule.Backend.Service.Name)
+
+		if messages := validation.IsDNS1123Subdomain(tenant); len(messages) > 0 {
+			return nil, fmt.Errorf("line %d: the tenant '%s' is not a valid name: %s", line, tenant, strings.Join(messages, ", "))
+		}
+
+		key := namespace + "/" + tenant
+		ingress, ok := ingresses[key]
+		if !ok {
+			ingress = &kasicov1.Ingress{
+				TypeMeta:   metav1.TypeMeta{APIVersion: kasicov1.GroupVersion.String(), Kind: "Ingress"},
+				ObjectMeta: metav1.ObjectMeta{Name: tenant, Namespace: namespace},
+				Spec:       kasicov1.IngressSpec{IngressClassName: class},
+			}
+			ingresses[key] = ingress
+			order = append(order, key)
+		} else if ingress.Spec.IngressClassName != class {
+			return nil, fmt.Errorf("line %d: the tenant %s has rows of the classes '%s' and '%s'", line, key, ingress.Spec.IngressClassName, class)
+		}
+
+		ruleKey := key + "|" + rule.Sip.Domain + "|" + rule.Sip.Headnumber + "|" + rule.Backend.Service.Name
+		if rules[ruleKey] {
+			result.Duplicates++
+			continue
+		}
+		rules[ruleKey] = true
+
+		ingress.Spec.Rules = append(ingress.Spec.Rules, rule)
+	}
+
+	for _, key := range order {
+		result.Ingresses = append(result.Ingresses, *ingresses[key])
+	}
+
+	return result, nil
+}
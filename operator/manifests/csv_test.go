@@ -0,0 +1,40 @@
+package manifests
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const testCSV = `# numbers of the carrier
+Domain,Headnumber,Service,Namespace,Class,Tenant
+sip.example.com,+4351233,pbx-a,,,
+,+4351244,pbx-a,,,
+,+4351244,pbx-a,,,
+,+4351255,pbx-b,tenants,,tenant-b
+,+4351266,pbx-b,tenants,,tenant-b
+`
+
+func TestReadIngressCSV(t *testing.T) {
+	result, err := ReadIngressCSV(strings.NewReader(testCSV), CSVOptions{Namespace: "default", IngressClassName: "default"})
+	assert.NoError(t, err)
+	assert.Equal(t, 5, result.Rows)
+	assert.Equal(t, 1, result.Duplicates)
+
+	assert.Len(t, result.Ingresses, 2)
+	a, b := result.Ingresses[0], result.Ingresses[1]
+	assert.Equal(t, "default/pbx-a", a.Namespace+"/"+a.Name)
+	assert.Equal(t, "Ingress", a.Kind)
+	assert.Equal(t, "default", a.Spec.IngressClassName)
+	assert.Len(t, a.Spec.Rules, 2)
+	assert.Equal(t, "sip.example.com", a.Spec.Rules[0].Sip.Domain)
+	assert.Equal(t, "tenants/tenant-b", b.Namespace+"/"+b.Name)
+	assert.Len(t, b.Spec.Rules, 2)
+
+	_, err = ReadIngressCSV(strings.NewReader("headnumber,service\n+43,pbx\n+44,\n"), CSVOptions{})
+	assert.EqualError(t, err, "line 3: the service is missing")
+
+	_, err = ReadIngressCSV(strings.NewReader("headnumber,service,class\n+43,pbx,a\n+44,pbx,b\n"), CSVOptions{})
+	assert.EqualError(t, err, "line 3: the tenant /pbx has rows of the classes 'a' and 'b'")
+}
@@ -0,0 +1,288 @@
+// Package manifests reads kubernetes manifests from local files, so the routing-data
+// can be computed and rendered without a cluster.
+package manifests
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	kasicov1 "github.com/world-direct/kasico/operator/api/v1"
+	"github.com/world-direct/kasico/operator/controllers"
+	"github.com/world-direct/kasico/operator/sidecar"
+	corev1 "k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/serializer"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	utilyaml "k8s.io/apimachinery/pkg/util/yaml"
+	"sigs.k8s.io/yaml"
+)
+
+var (
+	scheme  = runtime.NewScheme()
+	decoder runtime.Decoder
+)
+
+func init() {
+	utilruntime.Must(corev1.AddToScheme(scheme))
+	utilruntime.Must(discoveryv1.AddToScheme(scheme))
+	utilruntime.Must(kasicov1.AddToScheme(scheme))
+	decoder = serializer.NewCodecFactory(scheme).UniversalDeserializer()
+}
+
+// Set contains the objects read from the manifests, in the order they have been read
+type Set struct {
+	RouterInstances []kasicov1.RouterInstance
+	Ingresses       []kasicov1.Ingress
+	ConfigMaps      []corev1.ConfigMap
+	Secrets         []corev1.Secret
+	Services        []corev1.Service
+	EndpointSlices  []discoveryv1.EndpointSlice
+
+	// Skipped are the kinds of the documents which are not used
+	Skipped []string
+}
+
+// Load reads all manifests of the paths, which are YAML or JSON files, or directories
+// containing them. Objects without namespace are put into the given namespace.
+func Load(namespace string, paths ...string) (*Set, error) {
+	set := &Set{}
+	for _, path := range paths {
+		err := set.AddPath(namespace, path)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return set, nil
+}
+
+// AddPath adds the objects of a file, or of all *.yaml, *.yml and *.json files in a directory tree
+func (set *Set) AddPath(namespace string, path string) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+
+	if !info.IsDir() {
+		return set.AddFile(namespace, path)
+	}
+
+	files := []string{}
+	err = filepath.WalkDir(path, func(file string, entry os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		switch strings.ToLower(filepath.Ext(file)) {
+		case ".yaml", ".yml", ".json":
+			if !entry.IsDir() {
+				files = append(files, file)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	sort.Strings(files)
+	for _, file := range files {
+		err = set.AddFile(namespace, file)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// AddFile adds the objects of all documents in a file, '-' reads from stdin
+func (set *Set) AddFile(namespace string, path string) error {
+	var reader io.Reader = os.Stdin
+	if path != "-" {
+		file, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		reader = file
+	}
+
+	err := set.Add(namespace, reader)
+	if err != nil {
+		return fmt.Errorf("unable to read %s: %w", path, err)
+	}
+
+	return nil
+}
+
+// Add adds the objects of all YAML documents of the reader
+func (set *Set) Add(namespace string, reader io.Reader) error {
+	documents := utilyaml.NewYAMLReader(bufio.NewReader(reader))
+	for {
+		document, err := documents.Read()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		if len(bytes.TrimSpace(document)) == 0 {
+			continue
+		}
+
+		err = set.addDocument(namespace, document)
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func (set *Set) addDocument(namespace string, document []byte) error {
+	obj, gvk, err := decoder.Decode(document, nil, nil)
+	// documents which are no kubernetes objects, e.g. other JSON files, are skipped as well
+	if runtime.IsNotRegisteredError(err) || runtime.IsMissingKind(err) {
+		typeMeta := metav1.TypeMeta{}
+		_ = yaml.Unmarshal(document, &typeMeta)
+		set.Skipped = append(set.Skipped, typeMeta.Kind)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	switch obj := obj.(type) {
+	case *kasicov1.RouterInstance:
+		defaultNamespace(&obj.Namespace, namespace)
+		set.RouterInstances = append(set.RouterInstances, *obj)
+	case *kasicov1.Ingress:
+		defaultNamespace(&obj.Namespace, namespace)
+		set.Ingresses = append(set.Ingresses, *obj)
+	case *corev1.ConfigMap:
+		defaultNamespace(&obj.Namespace, namespace)
+		set.ConfigMaps = append(set.ConfigMaps, *obj)
+	case *corev1.Secret:
+		defaultNamespace(&obj.Namespace, namespace)
+		// the API server merges stringData into data, which is not done by the decoder
+		for key, value := range obj.StringData {
+			if obj.Data == nil {
+				obj.Data = map[string][]byte{}
+			}
+			obj.Data[key] = []byte(value)
+		}
+		set.Secrets = append(set.Secrets, *obj)
+	case *corev1.Service:
+		defaultNamespace(&obj.Namespace, namespace)
+		set.Services = append(set.Services, *obj)
+	case *discoveryv1.EndpointSlice:
+		defaultNamespace(&obj.Namespace, namespace)
+		set.EndpointSlices = append(set.EndpointSlices, *obj)
+	default:
+		set.Skipped = append(set.Skipped, gvk.Kind)
+	}
+
+	return nil
+}
+
+func defaultNamespace(namespace *string, defaultValue string) {
+	if *namespace == "" {
+		*namespace = defaultValue
+	}
+}
+
+// RouterInstance returns the RouterInstance with the name, or the only one if the name is empty
+func (set *Set) RouterInstance(name string) (*kasicov1.RouterInstance, error) {
+	if name == "" {
+		if len(set.RouterInstances) != 1 {
+			return nil, fmt.Errorf("expected exactly one RouterInstance, found %d", len(set.RouterInstances))
+		}
+		return &set.RouterInstances[0], nil
+	}
+
+	for i := range set.RouterInstances {
+		if set.RouterInstances[i].Name == name {
+			return &set.RouterInstances[i], nil
+		}
+	}
+
+	return nil, fmt.Errorf("RouterInstance %s not found", name)
+}
+
+// Backends returns the Services and EndpointSlices for GetRoutingData.
+// If the set contains no Services, nil is returned, so no rules are dropped.
+func (set *Set) Backends() *controllers.Backends {
+	if len(set.Services) == 0 {
+		return nil
+	}
+
+	return &controllers.Backends{Services: set.Services, EndpointSlices: set.EndpointSlices}
+}
+
+// RouterTemplates returns the templates of the ConfigMaps of the RouterInstance, layered in the
+// order of its spec, like the sidecar does. Like the sidecar, the binaryData is taken as static files.
+func (set *Set) RouterTemplates(router *kasicov1.RouterInstance) (map[string]string, error) {
+	layers := []map[string]string{}
+	for _, name := range router.Spec.TemplateSources() {
+		cm := set.configMap(router.Namespace, name)
+		if cm == nil {
+			return nil, fmt.Errorf("the template ConfigMap %s/%s is not in the manifests", router.Namespace, name)
+		}
+
+		layer, err := sidecar.TemplateData(cm)
+		if err != nil {
+			return nil, err
+		}
+		layers = append(layers, layer)
+	}
+
+	return sidecar.MergeLayers(layers...), nil
+}
+
+// RouterSecrets returns the data of the spec.templateSecrets of the RouterInstance, layered in order
+func (set *Set) RouterSecrets(router *kasicov1.RouterInstance) (map[string]string, error) {
+	layers := []map[string]string{}
+	for _, name := range router.Spec.TemplateSecrets {
+		secret := set.secret(router.Namespace, name)
+		if secret == nil {
+			return nil, fmt.Errorf("the Secret %s/%s is not in the manifests", router.Namespace, name)
+		}
+
+		layer := map[string]string{}
+		for key, value := range secret.Data {
+			layer[key] = string(value)
+		}
+		layers = append(layers, layer)
+	}
+
+	return sidecar.MergeLayers(layers...), nil
+}
+
+func (set *Set) configMap(namespace string, name string) *corev1.ConfigMap {
+	for i := range set.ConfigMaps {
+		if set.ConfigMaps[i].Namespace == namespace && set.ConfigMaps[i].Name == name {
+			return &set.ConfigMaps[i]
+		}
+	}
+
+	return nil
+}
+
+func (set *Set) secret(namespace string, name string) *corev1.Secret {
+	for i := range set.Secrets {
+		if set.Secrets[i].Namespace == namespace && set.Secrets[i].Name == name {
+			return &set.Secrets[i]
+		}
+	}
+
+	return nil
+}
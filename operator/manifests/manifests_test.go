@@ -0,0 +1,100 @@
+package manifests
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	kasicov1 "github.com/world-direct/kasico/operator/api/v1"
+)
+
+const testManifests = `
+apiVersion: kasico.world-direct.at/v1
+kind: Ingress
+metadata:
+  name: tenant-a
+spec:
+  ingressClassName: default
+  rules:
+  - sip:
+      domain: sip.example.com
+      headnumber: "+4351233"
+    backend:
+      service:
+        name: pbx-a
+---
+apiVersion: v1
+kind: Service
+metadata:
+  name: pbx-a
+  namespace: tenants
+---
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: pbx-a
+---
+apiVersion: v1
+kind: Secret
+metadata:
+  name: credentials
+data:
+  user: YWRtaW4=
+stringData:
+  password: secret
+`
+
+func TestAdd(t *testing.T) {
+	set := &Set{}
+	err := set.Add("default", strings.NewReader(testManifests))
+	assert.NoError(t, err)
+
+	assert.Equal(t, 1, len(set.Ingresses))
+	assert.Equal(t, "default", set.Ingresses[0].Namespace)
+	assert.Equal(t, "+4351233", set.Ingresses[0].Spec.Rules[0].Sip.Headnumber)
+
+	assert.Equal(t, 1, len(set.Services))
+	assert.Equal(t, "tenants", set.Services[0].Namespace)
+
+	assert.Equal(t, []string{"Deployment"}, set.Skipped)
+	router := &kasicov1.RouterInstance{ObjectMeta: metav1.ObjectMeta{Namespace: "default"}}
+	router.Spec.TemplateSecrets = []string{"credentials"}
+	secrets, err := set.RouterSecrets(router)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"user": "admin", "password": "secret"}, secrets)
+
+	router.Spec.TemplateSecrets = []string{"missing"}
+	_, err = set.RouterSecrets(router)
+	assert.EqualError(t, err, "the Secret default/missing is not in the manifests")
+}
+
+func TestLoad_Directory(t *testing.T) {
+	dir := t.TempDir()
+	assert.NoError(t, os.MkdirAll(filepath.Join(dir, "b"), 0755))
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "a.yaml"), []byte("apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: base\ndata:\n  a.cfg: base\n  b.cfg: base\n"), 0644))
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "b", "override.yml"), []byte("apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: override\ndata:\n  b.cfg: override\n"), 0644))
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "README.md"), []byte("# not a manifest"), 0644))
+
+	set, err := Load("default", dir)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(set.ConfigMaps))
+
+	// the layers are ordered by the spec of the RouterInstance, not by the files
+	router := &kasicov1.RouterInstance{ObjectMeta: metav1.ObjectMeta{Namespace: "default"}}
+	router.Spec.TemplateConfigMaps = []string{"override", "base"}
+	templates, err := set.RouterTemplates(router)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"a.cfg": "base", "b.cfg": "base"}, templates)
+
+	router.Spec.TemplateConfigMaps = []string{"override"}
+	templates, err = set.RouterTemplates(router)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"b.cfg": "override"}, templates)
+
+	_, err = set.RouterInstance("")
+	assert.Error(t, err)
+}
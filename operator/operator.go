@@ -0,0 +1,227 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/healthz"
+
+	configv1alpha1 "github.com/world-direct/kasico/operator/api/config/v1alpha1"
+	"github.com/world-direct/kasico/operator/controllers"
+)
+
+type operatorOptions struct {
+	configFile           string
+	namespace            string
+	metricsAddr          string
+	probeAddr            string
+	enableLeaderElection bool
+	debounceTime         time.Duration
+	maxWait              time.Duration
+	routerImage          string
+	controllerImage      string
+}
+
+func newOperatorCommand() *cobra.Command {
+	var opts operatorOptions
+	operatorCmd := &cobra.Command{
+		Use:   "operator",
+		Short: "Runs the kasico operator",
+		Long: `Runs the operator, watching the --namespace, or all namespaces if not set.
+The settings are read from the --config file, an OperatorConfig of config.kasico.world-direct.at/v1alpha1,
+and are overridden by the flags given.`,
+		Run: func(cmd *cobra.Command, args []string) {
+			opts.namespace = *configFlags.Namespace
+			main_operator(cmd.Flags(), opts)
+		},
+	}
+
+	addOperatorFlags(operatorCmd.Flags(), &opts)
+
+	return operatorCmd
+}
+
+func addOperatorFlags(flags *pflag.FlagSet, opts *operatorOptions) {
+	flags.StringVar(&opts.configFile, "config", "", "The OperatorConfig file to load the settings from")
+	flags.StringVar(&opts.metricsAddr, "metrics-bind-address", ":8080", "The address the metric endpoint binds to.")
+	flags.StringVar(&opts.probeAddr, "health-probe-bind-address", ":8081", "The address the probe endpoint binds to.")
+	flags.BoolVar(&opts.enableLeaderElection, "leader-elect", false, "Enable leader election for controller manager. ")
+	flags.DurationVar(&opts.debounceTime, "debounce-time", time.Second*5, "The time without further changes, after which the routing-data is generated")
+	flags.DurationVar(&opts.maxWait, "max-wait", 0, "The longest time a change is delayed, if changes keep coming in. 0 waits without limit.")
+	flags.StringVar(&opts.routerImage, "router-image", controllers.Default_RouterImage, "The image of the kamailio container of the router pods")
+	flags.StringVar(&opts.controllerImage, "controller-image", defaultControllerImage(), "The image of the kasico controller sidecar of the router pods, defaults to the image of the operator")
+}
+
+// defaultControllerImage returns the image of the operator from its environment, as the sidecar is the same binary
+func defaultControllerImage() string {
+	if image := os.Getenv(controllers.Env_ControllerImage); image != "" {
+		return image
+	}
+	return controllers.Default_ControllerImage
+}
+
+// loadOperatorConfig reads the config file, and overrides its values with the flags set.
+// The values missing in both are taken from the flag defaults.
+func loadOperatorConfig(flags *pflag.FlagSet, opts operatorOptions) (ctrl.Options, *configv1alpha1.OperatorConfig, error) {
+	config := &configv1alpha1.OperatorConfig{}
+	options := ctrl.Options{Scheme: scheme}
+
+	if opts.configFile != "" {
+		var err error
+		options, err = options.AndFrom(ctrl.ConfigFile().AtPath(opts.configFile).OfKind(config))
+		if err != nil {
+			return options, nil, fmt.Errorf("unable to load the config file %s: %w", opts.configFile, err)
+		}
+	}
+
+	if flags.Changed("metrics-bind-address") || options.MetricsBindAddress == "" {
+		options.MetricsBindAddress = opts.metricsAddr
+	}
+	if flags.Changed("health-probe-bind-address") || options.HealthProbeBindAddress == "" {
+		options.HealthProbeBindAddress = opts.probeAddr
+	}
+	if flags.Changed("leader-elect") {
+		options.LeaderElection = opts.enableLeaderElection
+	}
+	if options.LeaderElectionID == "" {
+		options.LeaderElectionID = "kasico-lock.world-direct.at"
+	}
+	if options.Port == 0 {
+		options.Port = 9443
+	}
+
+	if opts.namespace != "" {
+		options.Namespace = opts.namespace
+	} else if len(config.Namespaces) == 1 {
+		options.Namespace = config.Namespaces[0]
+	} else if len(config.Namespaces) > 1 {
+		options.NewCache = cache.MultiNamespacedCacheBuilder(config.Namespaces)
+	}
+
+	if flags.Changed("debounce-time") || config.Generator.DebounceTime == nil {
+		config.Generator.DebounceTime = &metav1.Duration{Duration: opts.debounceTime}
+	}
+	if flags.Changed("max-wait") || config.Generator.MaxWait == nil {
+		config.Generator.MaxWait = &metav1.Duration{Duration: opts.maxWait}
+	}
+	if flags.Changed("router-image") || config.RouterImage == "" {
+		config.RouterImage = opts.routerImage
+	}
+	if flags.Changed("controller-image") || config.ControllerImage == "" {
+		config.ControllerImage = opts.controllerImage
+	}
+
+	return options, config, nil
+}
+
+func main_operator(flags *pflag.FlagSet, opts operatorOptions) {
+	setupLog.Info("Starting Kasico Operator")
+
+	options, config, err := loadOperatorConfig(flags, opts)
+	if err != nil {
+		setupLog.Error(err, "unable to load the operator config")
+		os.Exit(1)
+	}
+
+	// LeaderElectionReleaseOnCancel defines if the leader should step down voluntarily
+	// when the Manager ends. This requires the binary to immediately end when the
+	// Manager is stopped, otherwise, this setting is unsafe. Setting this significantly
+	// speeds up voluntary leader transitions as the new leader don't have to wait
+	// LeaseDuration time first.
+	//
+	// In the default scaffold provided, the program ends immediately after
+	// the manager stops, so would be fine to enable this option. However,
+	// if you are doing or is intended to do any operation such as perform cleanups
+	// after the manager stops then its usage might be unsafe.
+	// It can be enabled with leaderElection.leaderElectionReleaseOnCancel in the config file.
+	mgr, err := ctrl.NewManager(getConfigOrDie(), options)
+	if err != nil {
+		setupLog.Error(err, "unable to start manager")
+		os.Exit(1)
+	}
+
+	genenerator := controllers.NewGenerator(mgr.GetClient(), config.Generator.DebounceTime.Duration, config.Generator.MaxWait.Duration)
+
+	if err = (&controllers.RouterInstanceReconciler{
+		Client:          mgr.GetClient(),
+		Scheme:          mgr.GetScheme(),
+		Generator:       genenerator,
+		RouterImage:     config.RouterImage,
+		ControllerImage: config.ControllerImage,
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "RouterInstance")
+		os.Exit(1)
+	}
+
+	if err = (&controllers.IngressReconciler{
+		Client:    mgr.GetClient(),
+		Scheme:    mgr.GetScheme(),
+		Generator: genenerator,
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "Ingress")
+		os.Exit(1)
+	}
+
+	if err = (&controllers.BackendReconciler{
+		Client:    mgr.GetClient(),
+		Scheme:    mgr.GetScheme(),
+		Generator: genenerator,
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "Backend")
+		os.Exit(1)
+	}
+	if err = (&controllers.RolloutReconciler{
+		Client: mgr.GetClient(),
+		Scheme: mgr.GetScheme(),
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "Rollout")
+		os.Exit(1)
+	}
+	//+kubebuilder:scaffold:builder
+
+	if err := mgr.AddHealthzCheck("healthz", healthz.Ping); err != nil {
+		setupLog.Error(err, "unable to set up health check")
+		os.Exit(1)
+	}
+	if err := mgr.AddReadyzCheck("readyz", healthz.Ping); err != nil {
+		setupLog.Error(err, "unable to set up ready check")
+		os.Exit(1)
+	}
+
+	// served with the metrics, so it is protected by the same proxy. The metrics must stay
+	// bound to localhost, see config/default/manager_auth_proxy_patch.yaml
+	if err := mgr.AddMetricsExtraHandler("/routing-table", &controllers.RoutingTableHandler{Client: mgr.GetClient()}); err != nil {
+		setupLog.Error(err, "unable to set up the routing table endpoint")
+		os.Exit(1)
+	}
+
+	mgr.Add(genenerator)
+
+	setupLog.Info("starting manager")
+	if err := mgr.Start(ctrl.SetupSignalHandler()); err != nil {
+		setupLog.Error(err, "problem running manager")
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/spf13/pflag"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/world-direct/kasico/operator/controllers"
+)
+
+const testOperatorConfig = `apiVersion: config.kasico.world-direct.at/v1alpha1
+kind: OperatorConfig
+metrics:
+  bindAddress: 127.0.0.1:8080
+leaderElection:
+  leaderElect: true
+generator:
+  debounceTime: 10s
+routerImage: kamailio/kamailio:5.6.2-bullseye
+`
+
+func TestLoadOperatorConfig(t *testing.T) {
+	configFile := filepath.Join(t.TempDir(), "config.yaml")
+	assert.NoError(t, os.WriteFile(configFile, []byte(testOperatorConfig), 0644))
+
+	tests := []struct {
+		name          string
+		args          []string
+		metricsAddr   string
+		leaderElect   bool
+		debounceTime  time.Duration
+		routerImage   string
+		expectedError bool
+	}{
+		{
+			name:         "defaults",
+			args:         []string{},
+			metricsAddr:  ":8080",
+			debounceTime: 5 * time.Second,
+			routerImage:  controllers.Default_RouterImage,
+		},
+		{
+			name:         "file",
+			args:         []string{"--config", configFile},
+			metricsAddr:  "127.0.0.1:8080",
+			leaderElect:  true,
+			debounceTime: 10 * time.Second,
+			routerImage:  "kamailio/kamailio:5.6.2-bullseye",
+		},
+		{
+			name:         "flags",
+			args:         []string{"--metrics-bind-address", ":9090", "--debounce-time", "1s", "--router-image", "kamailio:test", "--leader-elect"},
+			metricsAddr:  ":9090",
+			leaderElect:  true,
+			debounceTime: time.Second,
+			routerImage:  "kamailio:test",
+		},
+		{
+			name: "flags override the file",
+			args: []string{"--config", configFile, "--metrics-bind-address", ":9090", "--debounce-time", "1s",
+				"--router-image", "kamailio:test", "--leader-elect=false"},
+			metricsAddr:  ":9090",
+			debounceTime: time.Second,
+			routerImage:  "kamailio:test",
+		},
+		{
+			name:         "shipped file",
+			args:         []string{"--config", "config/manager/controller_manager_config.yaml"},
+			metricsAddr:  "127.0.0.1:8080",
+			leaderElect:  true,
+			debounceTime: 5 * time.Second,
+			routerImage:  controllers.Default_RouterImage,
+		},
+		{
+			name:          "missing file",
+			args:          []string{"--config", filepath.Join(t.TempDir(), "missing.yaml")},
+			expectedError: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			opts := operatorOptions{}
+			flags := pflag.NewFlagSet("operator", pflag.ContinueOnError)
+			addOperatorFlags(flags, &opts)
+			assert.NoError(t, flags.Parse(test.args))
+
+			options, config, err := loadOperatorConfig(flags, opts)
+			if test.expectedError {
+				assert.Error(t, err)
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, test.metricsAddr, options.MetricsBindAddress)
+			assert.Equal(t, test.leaderElect, options.LeaderElection)
+			assert.Equal(t, test.debounceTime, config.Generator.DebounceTime.Duration)
+			assert.Equal(t, test.routerImage, config.RouterImage)
+			assert.Equal(t, controllers.Default_ControllerImage, config.ControllerImage)
+		})
+	}
+}
+
+func TestLoadOperatorConfig_ControllerImage(t *testing.T) {
+	// the Deployment of the operator passes its own image
+	t.Setenv(controllers.Env_ControllerImage, "kasico:1.0")
+
+	for _, args := range [][]string{{}, {"--config", "config/manager/controller_manager_config.yaml"}} {
+		opts := operatorOptions{}
+		flags := pflag.NewFlagSet("operator", pflag.ContinueOnError)
+		addOperatorFlags(flags, &opts)
+		assert.NoError(t, flags.Parse(args))
+
+		_, config, err := loadOperatorConfig(flags, opts)
+		assert.NoError(t, err)
+		assert.Equal(t, "kasico:1.0", config.ControllerImage, args)
+	}
+}
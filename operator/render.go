@@ -0,0 +1,144 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/spf13/cobra"
+
+	"github.com/world-direct/kasico/operator/controllers"
+	"github.com/world-direct/kasico/operator/manifests"
+	"github.com/world-direct/kasico/operator/sidecar"
+)
+
+type renderOptions struct {
+	router      []string
+	routerName  string
+	ingresses   []string
+	templates   []string
+	secrets     []string
+	routingData string
+	sidecar     sidecar.Options
+}
+
+func newRenderCommand() *cobra.Command {
+	var opts renderOptions
+	renderCmd := &cobra.Command{
+		Use:   "render",
+		Short: "Renders the configuration of a router from local manifests",
+		Long: `Computes the routing-data of the RouterInstance from local manifests, and renders the
+templates into the output directory, like the controller of a router pod would do.
+No cluster is needed. If the manifests contain Services, rules to missing backends are dropped.
+The template ConfigMaps and Secrets named in the spec of the RouterInstance are layered
+in the order of the spec, like in the router pods.
+Manifests without namespace are in the --namespace.`,
+		Example: `  kasico render --router routerinstance.yaml --ingresses ingresses/ --templates template-configmap.yaml --out ./out`,
+		Args:    cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return main_render(cmd.Context(), opts)
+		},
+	}
+
+	renderCmd.Flags().StringSliceVar(&opts.router, "router", nil, "The files or directories containing the RouterInstance")
+	renderCmd.Flags().StringVar(&opts.routerName, "router-name", "", "The name of the RouterInstance, if there are more than one")
+	renderCmd.Flags().StringSliceVar(&opts.ingresses, "ingresses", nil, "The files or directories containing the Ingresses, and optionally the backend Services and EndpointSlices")
+	renderCmd.Flags().StringSliceVar(&opts.templates, "templates", nil, "The files or directories containing the template ConfigMaps of the RouterInstance")
+	renderCmd.Flags().StringSliceVar(&opts.secrets, "secrets", nil, "The files or directories containing the spec.templateSecrets of the RouterInstance, available in the templates as .Secrets")
+	renderCmd.Flags().StringVar(&opts.routingData, "routing-data", "", "The file to write the routing-data JSON to, if set")
+	renderCmd.Flags().StringVar(&opts.sidecar.ConfigDirectory, "out", "", "The directory to write the rendered files to")
+	renderCmd.Flags().StringVar(&opts.sidecar.SqliteFile, "sqlite", "", "The path of the routing database to build, if set")
+	renderCmd.Flags().StringVar(&opts.sidecar.CheckCommand, "check", "", "A command to validate the rendered configuration, e.g. 'kamailio -c -f {dir}/kamailio.cfg'")
+	renderCmd.Flags().StringVar(&opts.sidecar.PythonCheck, "python-check", "", "The python interpreter to check the syntax of rendered *.py files, e.g. python3")
+	renderCmd.MarkFlagRequired("router")
+	renderCmd.MarkFlagRequired("templates")
+	renderCmd.MarkFlagRequired("out")
+
+	return renderCmd
+}
+
+func main_render(ctx context.Context, opts renderOptions) error {
+	namespace, err := currentNamespace()
+	if err != nil {
+		return err
+	}
+
+	routers, err := manifests.Load(namespace, opts.router...)
+	if err != nil {
+		return err
+	}
+
+	router, err := routers.RouterInstance(opts.routerName)
+	if err != nil {
+		return err
+	}
+
+	ingresses, err := manifests.Load(namespace, opts.ingresses...)
+	if err != nil {
+		return err
+	}
+
+	templates, err := manifests.Load(namespace, opts.templates...)
+	if err != nil {
+		return err
+	}
+
+	if len(router.Spec.TemplateSources()) == 0 {
+		return fmt.Errorf("the RouterInstance %s has no template ConfigMaps", router.Name)
+	}
+
+	secrets, err := manifests.Load(namespace, opts.secrets...)
+	if err != nil {
+		return err
+	}
+
+	routingData := controllers.GetRoutingData(*router, ingresses.Ingresses, ingresses.Backends())
+	routingDataJson, err := controllers.MarshalRoutingData(routingData)
+	if err != nil {
+		return err
+	}
+
+	if opts.routingData != "" {
+		err = os.WriteFile(opts.routingData, []byte(routingDataJson), 0644)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = os.MkdirAll(opts.sidecar.ConfigDirectory, 0755)
+	if err != nil {
+		return err
+	}
+
+	// kamailio is not running, so nothing is reloaded
+	opts.sidecar.NoActions = true
+	sc, err := sidecar.New(opts.sidecar)
+	if err != nil {
+		return err
+	}
+
+	// only the ConfigMaps and Secrets of the RouterInstance are used, in the order of its spec
+	templateData, err := templates.RouterTemplates(router)
+	if err != nil {
+		return err
+	}
+
+	secretData, err := secrets.RouterSecrets(router)
+	if err != nil {
+		return err
+	}
+
+	_, err = sc.Apply(ctx, &sidecar.Inputs{
+		Templates:   templateData,
+		RoutingData: routingDataJson,
+		Secrets:     secretData,
+	})
+	if err != nil {
+		return err
+	}
+
+	setupLog.Info("Configuration rendered", "routerInstance", router.Name, "rules", len(routingData.Rules),
+		"directory", filepath.Clean(opts.sidecar.ConfigDirectory))
+	return nil
+}
@@ -0,0 +1,190 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/world-direct/kasico/operator/controllers"
+)
+
+type routeTestOptions struct {
+	allNamespaces bool
+	filenames     []string
+	routerName    string
+	requestURI    string
+	to            string
+	from          string
+	output        string
+}
+
+func newRouteCommand() *cobra.Command {
+	routeCmd := &cobra.Command{
+		Use:   "route",
+		Short: "Inspects the routing of SIP requests",
+	}
+
+	var opts routeTestOptions
+	routeTestCmd := &cobra.Command{
+		Use:   "test",
+		Short: "Shows the rule and backend a request is routed to",
+		Long: `Shows for each RouterInstance the Ingress rule and backend a request would be routed to,
+the less specific rules also matching it, and the rules shadowed by the selected one.
+Like in the routers, a rule of the domain of the Request-URI wins, otherwise the longest
+headnumber the user of the To URI starts with. The numbers are compared as they are.
+With --filename the routing-data is computed from local manifests, otherwise the published
+routing-data is read from the cluster. Manifests without namespace are in the --namespace.`,
+		Example: `  kasico route test --request-uri sip:+43512334455123@sip.example.com
+  kasico route test --request-uri sip:sip.example.com --to sip:+43512334455123@sip.example.com -f manifests/`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return main_route_test(cmd.Context(), cmd.OutOrStdout(), opts)
+		},
+	}
+
+	routeTestCmd.Flags().BoolVarP(&opts.allNamespaces, "all-namespaces", "A", false, "Test the RouterInstances of all namespaces")
+	routeTestCmd.Flags().StringSliceVarP(&opts.filenames, "filename", "f", nil, "The files or directories containing the RouterInstances, Ingresses and optionally Services, instead of the cluster")
+	routeTestCmd.Flags().StringVar(&opts.routerName, "router", "", "The name of the RouterInstance, all if empty")
+	routeTestCmd.Flags().StringVarP(&opts.requestURI, "request-uri", "r", "", "The Request-URI, e.g. sip:+43512334455123@sip.example.com")
+	routeTestCmd.Flags().StringVar(&opts.to, "to", "", "The To URI, whose user is the number routed by, the Request-URI if empty")
+	routeTestCmd.Flags().StringVar(&opts.from, "from", "", "The From URI, which is only shown, as it is not used for routing")
+	routeTestCmd.Flags().StringVarP(&opts.output, "output", "o", "text", "The output format: text or json")
+	routeTestCmd.MarkFlagRequired("request-uri")
+
+	routeCmd.AddCommand(routeTestCmd)
+	routeCmd.AddCommand(newRouteExportCommand())
+	return routeCmd
+}
+
+type routeExportOptions struct {
+	allNamespaces bool
+	filenames     []string
+	routerName    string
+	output        string
+}
+
+func newRouteExportCommand() *cobra.Command {
+	var opts routeExportOptions
+	routeExportCmd := &cobra.Command{
+		Use:   "export",
+		Short: "Exports the routing table of the RouterInstances",
+		Long: `Exports the lookup tables of the routers of every RouterInstance: every domain and headnumber,
+after the conflicts are resolved like in the routers, with the owner Ingress and the backend.
+With --filename the routing-data is computed from local manifests, otherwise the published
+routing-data is read from the cluster. The operator serves the same tables at /routing-table
+of the metrics endpoint, e.g. /routing-table?format=csv&namespace=kasico-default, behind the
+auth proxy, which requires the metrics-reader ClusterRole.`,
+		Example: `  kasico route export -o csv > routing-table.csv
+  kasico route export -A -o markdown`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return main_route_export(cmd.Context(), cmd.OutOrStdout(), opts)
+		},
+	}
+
+	routeExportCmd.Flags().BoolVarP(&opts.allNamespaces, "all-namespaces", "A", false, "Export the RouterInstances of all namespaces")
+	routeExportCmd.Flags().StringSliceVarP(&opts.filenames, "filename", "f", nil, "The files or directories containing the RouterInstances, Ingresses and optionally Services, instead of the cluster")
+	routeExportCmd.Flags().StringVar(&opts.routerName, "router", "", "The name of the RouterInstance, all if empty")
+	routeExportCmd.Flags().StringVarP(&opts.output, "output", "o", "csv", "The output format: csv, json or markdown")
+
+	return routeExportCmd
+}
+
+// routeTestResult is the result of a RouterInstance
+type routeTestResult struct {
+	RouterInstance   string                   `json:"routerInstance"`
+	IngressClassName string                   `json:"ingressClassName"`
+	Generation       int                      `json:"generation"`
+	Request          controllers.RouteRequest `json:"request"`
+	From             string                   `json:"from,omitempty"`
+	Match            *controllers.RouteMatch  `json:"match"`
+}
+
+func main_route_test(ctx context.Context, out io.Writer, opts routeTestOptions) error {
+	request, err := controllers.NewRouteRequest(opts.requestURI, opts.to)
+	if err != nil {
+		return err
+	}
+
+	routers, err := loadRoutingData(ctx, opts.filenames, opts.allNamespaces, opts.routerName)
+	if err != nil {
+		return err
+	}
+
+	results := []routeTestResult{}
+	for _, router := range routers {
+		results = append(results, routeTestResult{
+			RouterInstance:   router.Router.Namespace + "/" + router.Router.Name,
+			IngressClassName: router.Router.Spec.IngressClassName,
+			Generation:       router.RoutingData.Generation,
+			Request:          request,
+			From:             opts.from,
+			Match:            controllers.MatchRoute(router.RoutingData, request),
+		})
+	}
+
+	switch opts.output {
+	case "json":
+		return printJSON(out, results)
+	case "text":
+		for i, result := range results {
+			if i > 0 {
+				fmt.Fprintln(out)
+			}
+			printRouteTestResult(out, &result)
+		}
+		return nil
+	}
+
+	return fmt.Errorf("unknown output format %s", opts.output)
+}
+
+func printRouteTestResult(out io.Writer, result *routeTestResult) {
+	fmt.Fprintf(out, "RouterInstance %s (class %s, generation %d)\n", result.RouterInstance, result.IngressClassName, result.Generation)
+	fmt.Fprintf(out, "  request:   domain %q, number %q\n", result.Request.Domain, result.Request.Number)
+	if result.From != "" {
+		fmt.Fprintf(out, "  from:      %s\n", result.From)
+	}
+
+	match := result.Match
+	if match == nil {
+		fmt.Fprintln(out, "  selected:  none, the request is rejected")
+		return
+	}
+
+	fmt.Fprintf(out, "  selected:  %s, matched by %s\n", match.Rule, match.MatchType)
+	fmt.Fprintf(out, "  ingress:   %s\n", match.Rule.Owner)
+	fmt.Fprintf(out, "  backend:   %s\n", match.Rule.Backend)
+	if len(match.Rule.Endpoints) > 0 {
+		fmt.Fprintf(out, "  endpoints: %s\n", strings.Join(match.Rule.Endpoints, ", "))
+	}
+
+	for _, rule := range match.Shadowed {
+		fmt.Fprintf(out, "  shadowed:  %s\n", rule)
+	}
+
+	for _, rule := range match.Candidates {
+		fmt.Fprintf(out, "  also matching, less specific: %s\n", rule)
+	}
+}
+
+func main_route_export(ctx context.Context, out io.Writer, opts routeExportOptions) error {
+	if _, ok := controllers.RoutingTableFormats[opts.output]; !ok {
+		return fmt.Errorf("unknown output format %s", opts.output)
+	}
+
+	routers, err := loadRoutingData(ctx, opts.filenames, opts.allNamespaces, opts.routerName)
+	if err != nil {
+		return err
+	}
+
+	tables := []controllers.RoutingTable{}
+	for _, router := range routers {
+		tables = append(tables, controllers.NewRoutingTable(&router.Router, router.RoutingData))
+	}
+
+	return controllers.WriteRoutingTables(out, opts.output, tables)
+}
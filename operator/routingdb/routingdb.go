@@ -0,0 +1,185 @@
+// Package routingdb builds a SQLite database out of the RoutingData.
+//
+// For large routing tables it is much cheaper for Kamailio to query the
+// tables with db_sqlite than to parse the routing-data.json in the KEMI
+// script for every request. The database contains:
+//   - dispatcher: one set per distinct backend
+//   - domain: all domains used in rules
+//   - htable: one entry per domain and per headnumber, as resolved by controllers.ResolveRoutes,
+//     mapping the RouteKey to the dispatcher set
+//
+// The file is built beside the target and moved into place with a rename, so
+// Kamailio never sees a half written database.
+package routingdb
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+
+	"github.com/world-direct/kasico/operator/controllers"
+
+	// pure go driver, so we can keep building with CGO_ENABLED=0
+	_ "modernc.org/sqlite"
+)
+
+const driverName = "sqlite"
+
+const (
+	metaRoutingDataHash = "routing-data-hash"
+	metaGeneration      = "generation"
+)
+
+// RouteKey returns the htable key_name of a domain or headnumber, like 'domain:sip.example.com'
+// or 'headnumber:+43512'. Like in controllers.MatchRoute, a router looks up the domain of the
+// request first, and otherwise the longest headnumber the number starts with.
+func RouteKey(matchType string, key string) string {
+	return matchType + ":" + key
+}
+
+// ReadHash returns the RouterDataHash the database at path has been built from.
+// If the file doesn't exist, an empty string is returned.
+func ReadHash(path string) (string, error) {
+	if _, err := os.Stat(path); errors.Is(err, os.ErrNotExist) {
+		return "", nil
+	}
+
+	db, err := sql.Open(driverName, path)
+	if err != nil {
+		return "", err
+	}
+	defer db.Close()
+
+	var hash string
+	err = db.QueryRow("SELECT value FROM kasico_meta WHERE name = ?", metaRoutingDataHash).Scan(&hash)
+	if err != nil {
+		return "", err
+	}
+
+	return hash, nil
+}
+
+// Build writes the database for routingData to path, if the existing file
+// has not already been built from the same routingDataHash.
+// It returns true if the file has been replaced.
+func Build(path string, routingData *controllers.RoutingData, routingDataHash string) (bool, error) {
+	existingHash, err := ReadHash(path)
+	if err == nil && existingHash == routingDataHash {
+		return false, nil
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return false, err
+	}
+	tmpPath := tmp.Name()
+	tmp.Close()
+	defer os.Remove(tmpPath)
+
+	err = write(tmpPath, routingData, routingDataHash)
+	if err != nil {
+		return false, fmt.Errorf("unable to build %s: %w", path, err)
+	}
+
+	err = os.Rename(tmpPath, path)
+	if err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+func write(path string, routingData *controllers.RoutingData, routingDataHash string) error {
+	db, err := sql.Open(driverName, path)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, stmt := range schema {
+		if _, err = tx.Exec(stmt); err != nil {
+			return err
+		}
+	}
+
+	for table, version := range schemaVersions {
+		if _, err = tx.Exec("INSERT INTO version (table_name, table_version) VALUES (?, ?)", table, version); err != nil {
+			return err
+		}
+	}
+
+	sets := dispatcherSets(routingData.Rules)
+	for backend, setid := range sets {
+		_, err = tx.Exec("INSERT INTO dispatcher (setid, destination, description) VALUES (?, ?, ?)",
+			setid, "sip:"+backend, backend)
+		if err != nil {
+			return err
+		}
+	}
+
+	domains := map[string]bool{}
+	for _, rule := range routingData.Rules {
+		if rule.Domain == "" || domains[rule.Domain] {
+			continue
+		}
+
+		domains[rule.Domain] = true
+		if _, err = tx.Exec("INSERT INTO domain (domain, did) VALUES (?, ?)", rule.Domain, rule.Domain); err != nil {
+			return err
+		}
+	}
+
+	// a key can only be used once, so the shadowed rules are skipped
+	entries, _ := controllers.ResolveRoutes(routingData.Rules)
+	for _, entry := range entries {
+		_, err = tx.Exec("INSERT INTO htable (key_name, value_type, key_value) VALUES (?, ?, ?)",
+			RouteKey(entry.MatchType, entry.Key), htableValueInt, strconv.Itoa(sets[entry.Rule.Backend]))
+		if err != nil {
+			return err
+		}
+	}
+
+	meta := map[string]string{
+		metaRoutingDataHash: routingDataHash,
+		metaGeneration:      strconv.Itoa(routingData.Generation),
+	}
+	for name, value := range meta {
+		if _, err = tx.Exec("INSERT INTO kasico_meta (name, value) VALUES (?, ?)", name, value); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// dispatcherSets assigns a set id to each distinct backend. The ids are
+// assigned in sorted order, to keep them stable for the same set of backends.
+func dispatcherSets(rules []controllers.RoutingRule) map[string]int {
+	backends := []string{}
+	seen := map[string]bool{}
+	for _, rule := range rules {
+		if !seen[rule.Backend] {
+			seen[rule.Backend] = true
+			backends = append(backends, rule.Backend)
+		}
+	}
+
+	sort.Strings(backends)
+
+	sets := make(map[string]int, len(backends))
+	for i, backend := range backends {
+		sets[backend] = i + 1
+	}
+
+	return sets
+}
@@ -0,0 +1,80 @@
+package routingdb
+
+import (
+	"database/sql"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/world-direct/kasico/operator/controllers"
+)
+
+func testRoutingData() *controllers.RoutingData {
+	return &controllers.RoutingData{
+		UDPPort: 5060,
+		Rules: []controllers.RoutingRule{
+			{Domain: "tenant1.sip.example.org", Headnumber: "+43512334455", Owner: "default/a", Backend: "sip-server.default"},
+			{Domain: "tenant1.sip.example.org", Headnumber: "+43512334456", Owner: "default/a", Backend: "sip-server.default"},
+			{Domain: "tenant2.sip.example.org", Owner: "other/b", Backend: "pbx.other"},
+		},
+	}
+}
+
+func TestBuild(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "routing.db")
+
+	changed, err := Build(path, testRoutingData(), "md5:1")
+	assert.NoError(t, err)
+	assert.True(t, changed)
+
+	hash, err := ReadHash(path)
+	assert.NoError(t, err)
+	assert.Equal(t, "md5:1", hash)
+
+	db, err := sql.Open(driverName, path)
+	assert.NoError(t, err)
+	defer db.Close()
+
+	var count int
+	assert.NoError(t, db.QueryRow("SELECT count(*) FROM dispatcher").Scan(&count))
+	assert.Equal(t, 2, count)
+	assert.NoError(t, db.QueryRow("SELECT count(*) FROM domain").Scan(&count))
+	assert.Equal(t, 2, count)
+	// the domains and headnumbers are separate keys
+	keys := map[string]string{}
+	rows, err := db.Query("SELECT key_name, key_value FROM htable")
+	assert.NoError(t, err)
+	for rows.Next() {
+		var key, setid string
+		assert.NoError(t, rows.Scan(&key, &setid))
+		keys[key] = setid
+	}
+	assert.NoError(t, rows.Err())
+	assert.Equal(t, map[string]string{
+		"domain:tenant1.sip.example.org": "2",
+		"headnumber:+43512334455":        "2",
+		"headnumber:+43512334456":        "2",
+		"domain:tenant2.sip.example.org": "1",
+	}, keys)
+}
+
+func TestBuild_SameHash(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "routing.db")
+
+	_, err := Build(path, testRoutingData(), "md5:1")
+	assert.NoError(t, err)
+
+	changed, err := Build(path, testRoutingData(), "md5:1")
+	assert.NoError(t, err)
+	assert.False(t, changed)
+
+	changed, err = Build(path, testRoutingData(), "md5:2")
+	assert.NoError(t, err)
+	assert.True(t, changed)
+}
+
+func TestReadHash_Missing(t *testing.T) {
+	hash, err := ReadHash(filepath.Join(t.TempDir(), "missing.db"))
+	assert.NoError(t, err)
+	assert.Equal(t, "", hash)
+}
@@ -0,0 +1,64 @@
+package routingdb
+
+// The tables are created with the same layout Kamailio uses in its
+// 'utils/kamctl/sqlite' scripts, so the dispatcher, domain and htable modules
+// can load them with db_sqlite without any column mapping.
+// Kamailio checks the 'version' table before it uses a table, so every table
+// has to be registered there with the version the module expects.
+
+const tableVersion = `
+CREATE TABLE version (
+	id INTEGER PRIMARY KEY NOT NULL,
+	table_name VARCHAR(32) NOT NULL,
+	table_version INTEGER DEFAULT 0 NOT NULL,
+	CONSTRAINT version_table_name_idx UNIQUE (table_name)
+);`
+
+const tableDispatcher = `
+CREATE TABLE dispatcher (
+	id INTEGER PRIMARY KEY NOT NULL,
+	setid INTEGER DEFAULT 0 NOT NULL,
+	destination VARCHAR(192) DEFAULT '' NOT NULL,
+	flags INTEGER DEFAULT 0 NOT NULL,
+	priority INTEGER DEFAULT 0 NOT NULL,
+	attrs VARCHAR(128) DEFAULT '' NOT NULL,
+	description VARCHAR(64) DEFAULT '' NOT NULL
+);`
+
+const tableDomain = `
+CREATE TABLE domain (
+	id INTEGER PRIMARY KEY NOT NULL,
+	domain VARCHAR(64) NOT NULL,
+	did VARCHAR(64) DEFAULT NULL,
+	last_modified TIMESTAMP WITHOUT TIME ZONE DEFAULT '2000-01-01 00:00:01' NOT NULL,
+	CONSTRAINT domain_domain_idx UNIQUE (domain)
+);`
+
+const tableHtable = `
+CREATE TABLE htable (
+	id INTEGER PRIMARY KEY NOT NULL,
+	key_name VARCHAR(64) DEFAULT '' NOT NULL,
+	key_type INTEGER DEFAULT 0 NOT NULL,
+	value_type INTEGER DEFAULT 0 NOT NULL,
+	key_value VARCHAR(128) DEFAULT '' NOT NULL,
+	expires INTEGER DEFAULT 0 NOT NULL
+);`
+
+// tableMeta is not used by Kamailio, but allows us (and the templates) to see
+// which RoutingData the file has been built from
+const tableMeta = `
+CREATE TABLE kasico_meta (
+	name VARCHAR(64) PRIMARY KEY NOT NULL,
+	value VARCHAR(255) DEFAULT '' NOT NULL
+);`
+
+var schema = []string{tableVersion, tableDispatcher, tableDomain, tableHtable, tableMeta}
+
+var schemaVersions = map[string]int{
+	"dispatcher": 4,
+	"domain":     2,
+	"htable":     2,
+}
+
+// htableValueInt is the value type of the route keys, as defined by the htable module
+const htableValueInt = 1
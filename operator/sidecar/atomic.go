@@ -0,0 +1,169 @@
+package sidecar
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// The output directory is written like kubelet writes ConfigMap volumes:
+//
+//	<dir>/..kasico_<random>/<file>   the rendered files of one revision
+//	<dir>/..data -> ..kasico_<random> switched with an atomic rename
+//	<dir>/<file> -> ..data/<file>    stable paths used by kamailio
+//
+// So kamailio sees either the old or the new set of files, never a mix of both.
+
+const dataDirName = "..data"
+const revisionDirPrefix = "..kasico_"
+
+// WriteAtomic replaces all files in dir with files, the sensitive ones are only readable by the owner
+func WriteAtomic(dir string, files map[string][]byte, sensitive map[string]bool) error {
+	revisionDir, err := Stage(dir, files, sensitive)
+	if err != nil {
+		return err
+	}
+
+	return Activate(dir, revisionDir, files)
+}
+
+// Stage writes files into a new revision directory in dir, without activating them.
+// It returns the path of the revision directory, which is removed by Activate or Discard.
+func Stage(dir string, files map[string][]byte, sensitive map[string]bool) (string, error) {
+	err := os.MkdirAll(dir, 0755)
+	if err != nil {
+		return "", err
+	}
+
+	revisionDir, err := os.MkdirTemp(dir, revisionDirPrefix)
+	if err != nil {
+		return "", err
+	}
+
+	for name, content := range files {
+		path := filepath.Join(revisionDir, name)
+		err = os.MkdirAll(filepath.Dir(path), 0755)
+		if err == nil {
+			err = writeFile(path, content, sensitive[name])
+		}
+		if err != nil {
+			os.RemoveAll(revisionDir)
+			return "", err
+		}
+	}
+
+	return revisionDir, nil
+}
+
+// Discard removes a staged revision directory, which has not been activated
+func Discard(revisionDir string) error {
+	return os.RemoveAll(revisionDir)
+}
+
+// Activate switches dir to the files of the staged revision directory
+func Activate(dir string, revisionDir string, files map[string][]byte) error {
+	previousDir, _ := os.Readlink(filepath.Join(dir, dataDirName))
+
+	err := swapSymlink(dir, dataDirName, filepath.Base(revisionDir))
+	if err != nil {
+		os.RemoveAll(revisionDir)
+		return err
+	}
+
+	// like kubelet, only the top level entries are linked, nested files are reached through their directory
+	entries := topLevelEntries(files)
+	for name := range entries {
+		err = ensureFileLink(dir, name)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = removeStaleLinks(dir, entries)
+	if err != nil {
+		return err
+	}
+
+	if previousDir != "" && previousDir != filepath.Base(revisionDir) {
+		return os.RemoveAll(filepath.Join(dir, previousDir))
+	}
+
+	return nil
+}
+
+// writeFile writes the content with mode 0600 if it is sensitive, or 0644 otherwise
+func writeFile(path string, content []byte, sensitive bool) error {
+	mode := os.FileMode(0644)
+	if sensitive {
+		mode = 0600
+	}
+
+	return os.WriteFile(path, content, mode)
+}
+
+// swapSymlink atomically points dir/name to target
+func swapSymlink(dir string, name string, target string) error {
+	tmp := filepath.Join(dir, name+"_tmp")
+	os.Remove(tmp)
+
+	err := os.Symlink(target, tmp)
+	if err != nil {
+		return err
+	}
+
+	return os.Rename(tmp, filepath.Join(dir, name))
+}
+
+// ensureFileLink points dir/name to ..data/name, replacing regular files written before
+func ensureFileLink(dir string, name string) error {
+	target := filepath.Join(dataDirName, name)
+	existing, err := os.Readlink(filepath.Join(dir, name))
+	if err == nil && existing == target {
+		return nil
+	}
+
+	err = swapSymlink(dir, name, target)
+	if err != nil {
+		return fmt.Errorf("unable to link %s: %w", name, err)
+	}
+
+	return nil
+}
+
+// topLevelEntries returns the first path element of all files
+func topLevelEntries(files map[string][]byte) map[string]bool {
+	entries := map[string]bool{}
+	for name := range files {
+		entries[strings.SplitN(filepath.ToSlash(name), "/", 2)[0]] = true
+	}
+
+	return entries
+}
+
+// removeStaleLinks removes the links to entries not rendered anymore
+func removeStaleLinks(dir string, entries map[string]bool) error {
+	existing, err := os.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+
+	for _, entry := range existing {
+		name := entry.Name()
+		if entries[name] || strings.HasPrefix(name, "..") {
+			continue
+		}
+
+		target, err := os.Readlink(filepath.Join(dir, name))
+		if err != nil || !strings.HasPrefix(target, dataDirName+string(filepath.Separator)) {
+			continue
+		}
+
+		err = os.Remove(filepath.Join(dir, name))
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,279 @@
+package sidecar
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/go-logr/logr"
+
+	kasicov1 "github.com/world-direct/kasico/operator/api/v1"
+	"github.com/world-direct/kasico/operator/controllers"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/watch"
+	toolscache "k8s.io/client-go/tools/cache"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	ctrllog "sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// ConfigMapSource reads the Inputs from the routing-data and template ConfigMaps,
+// and calls the Sidecar every time one of them changes.
+// It implements 'manager.Runnable', so it is started with the manager.
+type ConfigMapSource struct {
+	Client  client.Reader
+	Cache   cache.Cache
+	Sidecar *Sidecar
+
+	// SecretsClient reads and watches the Secrets by name, so they are not cached, and only
+	// the named Secrets need to be readable. The Client is used if it isn't set.
+	SecretsClient client.WithWatch
+
+	Namespace            string
+	RoutingDataConfigMap string
+
+	// TemplatesConfigMaps are layered in order, see MergeLayers
+	TemplatesConfigMaps []string
+
+	// Secrets are layered in order, and available in the templates as .Secrets
+	Secrets []string
+
+	// RouterInstance is used for the TemplatesConfigMaps and Secrets, if none are given
+	RouterInstance string
+}
+
+// usesRouterInstance returns true, if the sources are taken from the RouterInstance
+func (source *ConfigMapSource) usesRouterInstance() bool {
+	return len(source.TemplatesConfigMaps) == 0 && source.RouterInstance != ""
+}
+
+// templateSources returns the names of the template ConfigMaps and Secrets
+func (source *ConfigMapSource) templateSources(ctx context.Context) ([]string, []string, error) {
+	if !source.usesRouterInstance() {
+		return source.TemplatesConfigMaps, source.Secrets, nil
+	}
+
+	router := &kasicov1.RouterInstance{}
+	err := source.Client.Get(ctx, types.NamespacedName{Namespace: source.Namespace, Name: source.RouterInstance}, router)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return router.Spec.TemplateSources(), router.Spec.TemplateSecrets, nil
+}
+
+// Load reads the Inputs from the ConfigMaps
+func (source *ConfigMapSource) Load(ctx context.Context) (*Inputs, error) {
+	cmRoutingData := &corev1.ConfigMap{}
+	err := source.Client.Get(ctx, types.NamespacedName{Namespace: source.Namespace, Name: source.RoutingDataConfigMap}, cmRoutingData)
+	if err != nil {
+		return nil, err
+	}
+
+	names, secretNames, err := source.templateSources(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(names) == 0 {
+		return nil, fmt.Errorf("no template ConfigMaps are configured")
+	}
+
+	layers := []map[string]string{}
+	for _, name := range names {
+		cmTemplates := &corev1.ConfigMap{}
+		err = source.Client.Get(ctx, types.NamespacedName{Namespace: source.Namespace, Name: name}, cmTemplates)
+		if err != nil {
+			return nil, err
+		}
+
+		templates, err := TemplateData(cmTemplates)
+		if err != nil {
+			return nil, err
+		}
+		layers = append(layers, templates)
+	}
+
+	secretLayers := []map[string]string{}
+	for _, name := range secretNames {
+		secret := &corev1.Secret{}
+		err = source.secretsReader().Get(ctx, types.NamespacedName{Namespace: source.Namespace, Name: name}, secret)
+		if err != nil {
+			return nil, err
+		}
+
+		values := map[string]string{}
+		for key, value := range secret.Data {
+			values[key] = string(value)
+		}
+		secretLayers = append(secretLayers, values)
+	}
+
+	return &Inputs{
+		Templates:   MergeLayers(layers...),
+		RoutingData: cmRoutingData.Data[controllers.Name_RouningDataJson],
+		Secrets:     MergeLayers(secretLayers...),
+	}, nil
+}
+
+func (source *ConfigMapSource) Start(ctx context.Context) error {
+	log := ctrllog.FromContext(ctx).WithName("configmaps")
+
+	// the events are only used as a signal, the ConfigMaps are read from the cache.
+	// As the template ConfigMaps may be taken from the RouterInstance, every ConfigMap
+	// in the namespace triggers a Load, unchanged inputs are skipped by the Sidecar.
+	changed := make(chan struct{}, 1)
+	notify := func() {
+		select {
+		case changed <- struct{}{}:
+		default:
+		}
+	}
+
+	onChange := func(obj interface{}) {
+		object, ok := obj.(client.Object)
+		if !ok || object.GetNamespace() != source.Namespace {
+			return
+		}
+
+		// the revisions are never used by the sidecar
+		if _, isRevision := object.GetLabels()[controllers.Name_LabelRoutingRevision]; isRevision {
+			return
+		}
+
+		notify()
+	}
+
+	handler := toolscache.ResourceEventHandlerFuncs{
+		AddFunc:    onChange,
+		UpdateFunc: func(_ interface{}, newObj interface{}) { onChange(newObj) },
+		DeleteFunc: onChange,
+	}
+
+	// the Secrets are watched by name, see watchSecrets
+	watched := []client.Object{&corev1.ConfigMap{}}
+	if source.usesRouterInstance() {
+		watched = append(watched, &kasicov1.RouterInstance{})
+	}
+
+	for _, object := range watched {
+		informer, err := source.Cache.GetInformer(ctx, object)
+		if err != nil {
+			return err
+		}
+		informer.AddEventHandler(handler)
+	}
+
+	// failed inputs are applied again after the RetryDelay of the Sidecar
+	retry := time.NewTimer(0)
+	<-retry.C
+	defer retry.Stop()
+
+	secretWatches := map[string]context.CancelFunc{}
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+
+		case <-retry.C:
+			notify()
+
+		case <-changed:
+			// the Secrets of the RouterInstance may have been changed
+			_, secretNames, err := source.templateSources(ctx)
+			if err != nil {
+				log.Error(err, "Unable to read the template sources")
+				continue
+			}
+			secretWatches = source.watchSecrets(ctx, log, secretWatches, secretNames, notify)
+
+			inputs, err := source.Load(ctx)
+			if err != nil {
+				log.Error(err, "Unable to read the ConfigMaps")
+				continue
+			}
+
+			_, err = source.Sidecar.Apply(ctx, inputs)
+			if err != nil {
+				log.Error(err, "Unable to apply the configuration")
+			}
+
+			if delay := source.Sidecar.RetryDelay(); delay > 0 {
+				retry.Reset(delay)
+			}
+		}
+	}
+}
+
+func (source *ConfigMapSource) secretsReader() client.Reader {
+	if source.SecretsClient != nil {
+		return source.SecretsClient
+	}
+
+	return source.Client
+}
+
+// secretWatchRetryDelay is the delay before a failed or closed Secret watch is restarted
+const secretWatchRetryDelay = 5 * time.Second
+
+// watchSecrets starts a watch for every named Secret, which isn't watched yet, and stops the
+// watches of the Secrets no longer used. It returns the running watches by name.
+// Every Secret is watched with a field selector on its name, instead of an informer on all
+// Secrets of the namespace, so the sidecar only sees, and needs access to the named Secrets.
+func (source *ConfigMapSource) watchSecrets(ctx context.Context, log logr.Logger, watches map[string]context.CancelFunc, names []string, notify func()) map[string]context.CancelFunc {
+	if source.SecretsClient == nil {
+		return watches
+	}
+
+	running := map[string]context.CancelFunc{}
+	for _, name := range names {
+		if cancel, exists := watches[name]; exists {
+			running[name] = cancel
+			continue
+		}
+
+		watchCtx, cancel := context.WithCancel(ctx)
+		running[name] = cancel
+		go source.watchSecret(watchCtx, log.WithValues("secret", name), name, notify)
+	}
+
+	for name, cancel := range watches {
+		if _, exists := running[name]; !exists {
+			cancel()
+		}
+	}
+
+	return running
+}
+
+// watchSecret notifies about every event of the Secret, until the context is done.
+// A restarted watch begins with the current Secret, so no change is lost in between.
+func (source *ConfigMapSource) watchSecret(ctx context.Context, log logr.Logger, name string, notify func()) {
+	for {
+		delay := time.Duration(0)
+		watcher, err := source.SecretsClient.Watch(ctx, &corev1.SecretList{},
+			client.InNamespace(source.Namespace), client.MatchingFields{"metadata.name": name})
+		if err != nil {
+			log.Error(err, "Unable to watch the Secret")
+			delay = secretWatchRetryDelay
+		} else {
+			for event := range watcher.ResultChan() {
+				if event.Type == watch.Error {
+					log.Error(apierrors.FromObject(event.Object), "The watch of the Secret failed")
+					delay = secretWatchRetryDelay
+					break
+				}
+				notify()
+			}
+			watcher.Stop()
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(delay):
+		}
+	}
+}
@@ -0,0 +1,213 @@
+package sidecar
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/world-direct/kasico/operator/controllers"
+	ctrllog "sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// the time to wait for further events, before the directories are read
+const directorySettleTime = 200 * time.Millisecond
+
+// the period to read the directories, even if no event has been received
+const directoryResyncPeriod = time.Minute
+
+// DirectorySource reads the Inputs from mounted ConfigMap volumes, and calls the
+// Sidecar every time one of them changes. This needs no access to the kubernetes API.
+type DirectorySource struct {
+	// TemplatesDirectories contain one file per template, and are layered in order, see MergeLayers.
+	// The binary files are static files, see DirectoryTemplateData.
+	TemplatesDirectories []string
+
+	// RoutingDataDirectory contains the routing-data.json
+	RoutingDataDirectory string
+
+	// SecretsDirectories are mounted Secret volumes, layered in order, available as .Secrets
+	SecretsDirectories []string
+
+	Sidecar *Sidecar
+}
+
+// Load reads the Inputs from the directories
+func (source *DirectorySource) Load() (*Inputs, error) {
+	layers := []map[string]string{}
+	for _, dir := range source.TemplatesDirectories {
+		files, err := ReadDirectory(dir)
+		if err != nil {
+			return nil, err
+		}
+
+		templates, err := DirectoryTemplateData(files)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read %s: %w", dir, err)
+		}
+		layers = append(layers, templates)
+	}
+
+	routingData, err := os.ReadFile(filepath.Join(source.RoutingDataDirectory, controllers.Name_RouningDataJson))
+	if err != nil {
+		return nil, err
+	}
+
+	secretLayers := []map[string]string{}
+	for _, dir := range source.SecretsDirectories {
+		secrets, err := ReadDirectory(dir)
+		if err != nil {
+			return nil, err
+		}
+		secretLayers = append(secretLayers, secrets)
+	}
+
+	return &Inputs{
+		Templates:   MergeLayers(layers...),
+		RoutingData: string(routingData),
+		Secrets:     MergeLayers(secretLayers...),
+	}, nil
+}
+
+// Start applies the inputs, and watches the directories with inotify until the context is done.
+// Kubelet updates ConfigMap volumes by swapping the '..data' symlink, which is seen as
+// an event on the directory, so the directories are watched instead of the files.
+func (source *DirectorySource) Start(ctx context.Context) error {
+	log := ctrllog.FromContext(ctx).WithName("directories")
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	defer watcher.Close()
+
+	dirs := append([]string{source.RoutingDataDirectory}, source.TemplatesDirectories...)
+	for _, dir := range append(dirs, source.SecretsDirectories...) {
+		err = watcher.Add(dir)
+		if err != nil {
+			return fmt.Errorf("unable to watch %s: %w", dir, err)
+		}
+	}
+
+	// failed inputs are applied again after the RetryDelay of the Sidecar
+	retry := time.NewTimer(0)
+	<-retry.C
+	defer retry.Stop()
+
+	apply := func() {
+		inputs, err := source.Load()
+		if err != nil {
+			log.Error(err, "Unable to read the directories")
+			return
+		}
+
+		_, err = source.Sidecar.Apply(ctx, inputs)
+		if err != nil {
+			log.Error(err, "Unable to apply the configuration")
+		}
+
+		if delay := source.Sidecar.RetryDelay(); delay > 0 {
+			retry.Reset(delay)
+		}
+	}
+
+	apply()
+
+	resync := time.NewTicker(directoryResyncPeriod)
+	defer resync.Stop()
+
+	// events are collected until the directories settle, so a swap is applied once
+	settle := time.NewTimer(0)
+	<-settle.C
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return nil
+			}
+			log.V(1).Info("Directory changed", "event", event.String())
+			settle.Reset(directorySettleTime)
+
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return nil
+			}
+			log.Error(err, "Error watching the directories")
+
+		case <-settle.C:
+			apply()
+
+		case <-resync.C:
+			apply()
+
+		case <-retry.C:
+			apply()
+		}
+	}
+}
+
+// DirectoryTemplateData returns the templates of a mounted template ConfigMap, like TemplateData.
+// In a volume the keys of the binaryData can't be told apart from the data, so the files which
+// are not valid UTF-8 are taken as static files. binaryData keys with valid UTF-8 content are
+// rendered as templates, unless they have the '.static' suffix.
+func DirectoryTemplateData(files map[string]string) (map[string]string, error) {
+	templates := make(map[string]string, len(files))
+	for name, content := range files {
+		if utf8.ValidString(content) || strings.HasSuffix(name, staticSuffix) {
+			templates[name] = content
+			continue
+		}
+
+		if _, exists := files[name+staticSuffix]; exists {
+			return nil, fmt.Errorf("the binary file %s conflicts with %s", name, name+staticSuffix)
+		}
+		templates[name+staticSuffix] = content
+	}
+
+	return templates, nil
+}
+
+// ReadDirectory returns the content of all files in dir by name, like the data of a ConfigMap.
+// Hidden files, like the '..data' link of a ConfigMap volume, and directories are skipped.
+func ReadDirectory(dir string) (map[string]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	files := map[string]string{}
+	for _, entry := range entries {
+		if strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+
+		path := filepath.Join(dir, entry.Name())
+
+		// the keys of a ConfigMap volume are symlinks, so we have to stat the target
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, err
+		}
+
+		if !info.Mode().IsRegular() {
+			continue
+		}
+
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read %s: %w", path, err)
+		}
+
+		files[entry.Name()] = string(content)
+	}
+
+	return files, nil
+}
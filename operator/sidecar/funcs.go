@@ -0,0 +1,281 @@
+package sidecar
+
+import (
+	"fmt"
+	"net"
+	"regexp"
+	"sort"
+	"strings"
+	"text/template"
+
+	"github.com/Masterminds/sprig/v3"
+	"github.com/world-direct/kasico/operator/controllers"
+)
+
+// FuncMap returns the functions available in the templates. These are the hermetic
+// sprig functions (no environment, time or randomness, so rendering stays
+// reproducible), plus the SIP helpers below.
+func FuncMap() template.FuncMap {
+	funcs := sprig.HermeticTxtFuncMap()
+
+	funcs["e164"] = E164
+	funcs["prefixTrie"] = PrefixTrieOf
+	funcs["prefixRegex"] = PrefixRegex
+	funcs["regexEscape"] = regexp.QuoteMeta
+	funcs["pyQuote"] = PyQuote
+	funcs["luaQuote"] = LuaQuote
+	funcs["cfgQuote"] = CfgQuote
+	funcs["backendUri"] = BackendURI
+
+	return funcs
+}
+
+// E164 normalizes a phone number to the E.164 format '+<digits>'.
+// Separators like spaces, dashes, dots, slashes and parentheses are removed, and
+// an international '00' prefix is replaced by '+'. National numbers starting with
+// a single '0' are prefixed with the optional country code, e.g.
+//
+//	{{ e164 "0043 1 234-56" }}   -> +43123456
+//	{{ e164 "01 23456" "43" }}   -> +4312345
+func E164(number string, countryCode ...string) (string, error) {
+	digits := strings.Map(func(r rune) rune {
+		switch {
+		case r >= '0' && r <= '9', r == '+':
+			return r
+		case strings.ContainsRune(" -./()", r):
+			return -1
+		default:
+			return '?'
+		}
+	}, strings.TrimSpace(number))
+
+	if strings.ContainsRune(digits, '?') || strings.LastIndex(digits, "+") > 0 {
+		return "", fmt.Errorf("invalid phone number '%s'", number)
+	}
+
+	switch {
+	case strings.HasPrefix(digits, "+"):
+		digits = digits[1:]
+	case strings.HasPrefix(digits, "00"):
+		digits = digits[2:]
+	case strings.HasPrefix(digits, "0") && len(countryCode) > 0:
+		digits = strings.TrimPrefix(countryCode[0], "+") + digits[1:]
+	default:
+		return "", fmt.Errorf("phone number '%s' is not international, and no country code is given", number)
+	}
+
+	if len(digits) == 0 || len(digits) > 15 {
+		return "", fmt.Errorf("invalid phone number '%s'", number)
+	}
+
+	return "+" + digits, nil
+}
+
+// PrefixTrie is a node of a number prefix trie. Each node represents one character
+// of the prefix, and holds the values of all prefixes ending there.
+type PrefixTrie struct {
+	// Prefix is the complete prefix up to this node
+	Prefix string
+
+	// Char is the last character of the Prefix
+	Char string
+
+	// Values of the prefixes ending at this node, empty for inner nodes
+	Values []interface{}
+
+	// Children sorted by Char
+	Children []*PrefixTrie
+}
+
+// Leaf returns true, if the node has no children
+func (node *PrefixTrie) Leaf() bool {
+	return len(node.Children) == 0
+}
+
+func (node *PrefixTrie) insert(prefix string, value interface{}) {
+	current := node
+	for i, char := range prefix {
+		var child *PrefixTrie
+		for _, existing := range current.Children {
+			if existing.Char == string(char) {
+				child = existing
+				break
+			}
+		}
+
+		if child == nil {
+			child = &PrefixTrie{Prefix: prefix[:i+len(string(char))], Char: string(char)}
+			current.Children = append(current.Children, child)
+			sort.Slice(current.Children, func(a, b int) bool { return current.Children[a].Char < current.Children[b].Char })
+		}
+
+		current = child
+	}
+
+	current.Values = append(current.Values, value)
+}
+
+// PrefixTrieOf builds a trie from a list of prefixes, a map of prefix to value, or a
+// list of routing rules (by Headnumber, with the rule as value). Recursive templates
+// can walk the returned root node to render nested lookups.
+func PrefixTrieOf(items interface{}) (*PrefixTrie, error) {
+	root := &PrefixTrie{}
+
+	switch items := items.(type) {
+	case []string:
+		for _, prefix := range items {
+			root.insert(prefix, prefix)
+		}
+	case []interface{}:
+		for _, prefix := range items {
+			root.insert(fmt.Sprint(prefix), prefix)
+		}
+	case map[string]string:
+		for prefix, value := range items {
+			root.insert(prefix, value)
+		}
+	case map[string]interface{}:
+		for prefix, value := range items {
+			root.insert(prefix, value)
+		}
+	case []controllers.RoutingRule:
+		for _, rule := range items {
+			if rule.Headnumber != "" {
+				root.insert(rule.Headnumber, rule)
+			}
+		}
+	default:
+		return nil, fmt.Errorf("prefixTrie: unsupported type %T", items)
+	}
+
+	return root, nil
+}
+
+// PrefixRegex renders the prefixes (see PrefixTrieOf) as one anchored regular expression,
+// sharing common prefixes, e.g. ["+431", "+4312", "+435"] -> ^\+43(1(2)?|5)
+// Without any prefix, an expression matching nothing is returned.
+func PrefixRegex(items interface{}) (string, error) {
+	root, err := PrefixTrieOf(items)
+	if err != nil {
+		return "", err
+	}
+
+	if root.Leaf() {
+		return `[^\s\S]`, nil
+	}
+
+	return "^" + trieRegex(root), nil
+}
+
+// trieRegex renders the alternatives below the node
+func trieRegex(node *PrefixTrie) string {
+	alternatives := []string{}
+	for _, child := range node.Children {
+		alternatives = append(alternatives, regexp.QuoteMeta(child.Char)+trieSuffix(child))
+	}
+
+	if len(alternatives) == 1 {
+		return alternatives[0]
+	}
+
+	return "(" + strings.Join(alternatives, "|") + ")"
+}
+
+// trieSuffix renders the part of the expression following the node
+func trieSuffix(node *PrefixTrie) string {
+	if node.Leaf() {
+		return ""
+	}
+
+	suffix := trieRegex(node)
+	if len(node.Values) > 0 {
+		// a prefix ends here, so the longer ones are optional
+		if !strings.HasPrefix(suffix, "(") || !strings.HasSuffix(suffix, ")") {
+			suffix = "(" + suffix + ")"
+		}
+		return suffix + "?"
+	}
+
+	return suffix
+}
+
+// quote returns s as quoted string literal, escaping the quote, backslashes and control characters
+func quote(s string, quoteChar rune, hexEscapes bool) string {
+	var builder strings.Builder
+	builder.WriteRune(quoteChar)
+
+	for _, r := range s {
+		switch {
+		case r == quoteChar || r == '\\':
+			builder.WriteRune('\\')
+			builder.WriteRune(r)
+		case r == '\n':
+			builder.WriteString(`\n`)
+		case r == '\r':
+			builder.WriteString(`\r`)
+		case r == '\t':
+			builder.WriteString(`\t`)
+		case r < 0x20 || r == 0x7f:
+			if hexEscapes {
+				fmt.Fprintf(&builder, `\x%02x`, r)
+			}
+		default:
+			builder.WriteRune(r)
+		}
+	}
+
+	builder.WriteRune(quoteChar)
+	return builder.String()
+}
+
+// PyQuote returns s as Python string literal
+func PyQuote(s string) string {
+	return quote(s, '\'', true)
+}
+
+// LuaQuote returns s as Lua string literal
+func LuaQuote(s string) string {
+	return quote(s, '"', true)
+}
+
+// CfgQuote returns s as string of the kamailio configuration language, which has no
+// hex escapes, so other control characters are dropped
+func CfgQuote(s string) string {
+	return quote(s, '"', false)
+}
+
+// BackendURI returns the SIP URI of a backend, which is a routing rule (using its Backend),
+// or a host with optional port, e.g. an endpoint. The optional transport is added as parameter.
+//
+//	{{ backendUri . "udp" }}            -> sip:svc.namespace;transport=udp
+//	{{ backendUri "fd00::1" }}          -> sip:[fd00::1]
+func BackendURI(backend interface{}, transport ...string) (string, error) {
+	var host string
+
+	switch backend := backend.(type) {
+	case string:
+		host = backend
+	case controllers.RoutingRule:
+		host = backend.Backend
+	case *controllers.RoutingRule:
+		host = backend.Backend
+	default:
+		return "", fmt.Errorf("backendUri: unsupported type %T", backend)
+	}
+
+	if host == "" {
+		return "", fmt.Errorf("backendUri: empty backend")
+	}
+
+	// IPv6 addresses must be enclosed in brackets, endpoints with a port already are
+	if ip := net.ParseIP(host); ip != nil && ip.To4() == nil {
+		host = "[" + host + "]"
+	}
+
+	uri := "sip:" + host
+	if len(transport) > 0 && transport[0] != "" {
+		uri += ";transport=" + strings.ToLower(transport[0])
+	}
+
+	return uri, nil
+}
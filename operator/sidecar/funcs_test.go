@@ -0,0 +1,71 @@
+package sidecar
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/world-direct/kasico/operator/controllers"
+)
+
+func TestE164(t *testing.T) {
+	for input, expected := range map[string]string{
+		"+43 1 234-56":  "+43123456",
+		"0043 (1) 2345": "+4312345",
+		"01/2345":       "+4312345",
+	} {
+		actual, err := E164(input, "43")
+		assert.NoError(t, err, input)
+		assert.Equal(t, expected, actual, input)
+	}
+
+	_, err := E164("01 2345")
+	assert.Error(t, err)
+
+	_, err = E164("+43 1 abc")
+	assert.Error(t, err)
+}
+
+func TestPrefixRegex(t *testing.T) {
+	regex, err := PrefixRegex([]string{"+431", "+4312", "+435"})
+	assert.NoError(t, err)
+	assert.Equal(t, `^\+43(1(2)?|5)`, regex)
+
+	trie, err := PrefixTrieOf([]controllers.RoutingRule{{Headnumber: "+431", Backend: "a"}, {Headnumber: "+432", Backend: "b"}})
+	assert.NoError(t, err)
+	node := trie.Children[0].Children[0].Children[0]
+	assert.Equal(t, "+43", node.Prefix)
+	assert.Equal(t, 2, len(node.Children))
+	assert.Equal(t, "b", node.Children[1].Values[0].(controllers.RoutingRule).Backend)
+}
+
+func TestQuote(t *testing.T) {
+	assert.Equal(t, `'it\'s a \\ test\n'`, PyQuote("it's a \\ test\n"))
+	assert.Equal(t, `"say \"hi\"\x01"`, LuaQuote("say \"hi\"\x01"))
+	assert.Equal(t, `"say \"hi\""`, CfgQuote("say \"hi\"\x01"))
+}
+
+func TestBackendURI(t *testing.T) {
+	uri, err := BackendURI(controllers.RoutingRule{Backend: "s1.default"}, "UDP")
+	assert.NoError(t, err)
+	assert.Equal(t, "sip:s1.default;transport=udp", uri)
+
+	uri, err = BackendURI("fd00::1")
+	assert.NoError(t, err)
+	assert.Equal(t, "sip:[fd00::1]", uri)
+
+	uri, err = BackendURI("10.0.0.1:5060")
+	assert.NoError(t, err)
+	assert.Equal(t, "sip:10.0.0.1:5060", uri)
+}
+
+func TestRender_Funcs(t *testing.T) {
+	rendered, err := Render(&Inputs{
+		Templates: map[string]string{
+			"routes.py": `ROUTES = { {{- range .Rules}}{{pyQuote .Headnumber}}: {{backendUri . "udp" | pyQuote}}{{end}} }`,
+		},
+		RoutingData: testRoutingData,
+	}, false)
+
+	assert.NoError(t, err)
+	assert.Equal(t, `ROUTES = {'+431': 'sip:s1.default;transport=udp' }`, string(rendered.Files["routes.py"]))
+}
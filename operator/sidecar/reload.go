@@ -0,0 +1,389 @@
+package sidecar
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/world-direct/kasico/operator/kamailio"
+	ctrllog "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/yaml"
+)
+
+// ActionsKey is the reserved template key declaring the reload actions. It is not rendered.
+// If it is missing, the ReloadActions of the Options are used. The exec and http actions
+// are only accepted, if the controller runs with --allow-exec-actions.
+//
+//	# _actions.yaml
+//	- files: [dispatcher.list]
+//	  rpc: dispatcher.reload
+//	- files: [routing-data.sqlite]
+//	  rpc: htable.reload routes
+//	- files: ["tls/*"]
+//	  signal: {process: kamailio, signal: SIGHUP}
+//	- files: [custom.cfg]
+//	  exec: [/scripts/apply.sh]
+//	- files: [acl.cfg]
+//	  http: {url: "http://127.0.0.1:8000/reload"}
+//	- files: [kamailio.cfg]
+//	  restart: {process: kamailio}
+const ActionsKey = "_actions.yaml"
+
+// the timeout of exec and HTTP actions
+const actionTimeout = 30 * time.Second
+
+// ReloadAction makes kamailio pick up changed files, without a restart if possible.
+// It is executed after one of the Files has been changed, and has exactly one of
+// RPC, Signal, Exec, HTTP or Restart set.
+type ReloadAction struct {
+	// Files are matched against the changed file paths with filepath.Match
+	Files []string `json:"files"`
+
+	// RPC is a kamailio RPC command, followed by its parameters
+	RPC string `json:"rpc,omitempty"`
+
+	// Signal sends a signal to a process, which needs a shared process namespace in the pod
+	Signal *SignalAction `json:"signal,omitempty"`
+
+	// Exec runs a command, with the changed files in $KASICO_CHANGED_FILES
+	Exec []string `json:"exec,omitempty"`
+
+	// HTTP calls an URL, and expects a 2xx status
+	HTTP *HTTPAction `json:"http,omitempty"`
+
+	// Restart terminates the process, so the container is restarted by kubelet
+	Restart *SignalAction `json:"restart,omitempty"`
+}
+
+// SignalAction identifies the process by the name of its executable
+type SignalAction struct {
+	Process string `json:"process"`
+
+	// Signal like SIGHUP, HUP or 1, defaults to SIGHUP (SIGTERM for restarts)
+	Signal string `json:"signal,omitempty"`
+}
+
+type HTTPAction struct {
+	URL string `json:"url"`
+
+	// Method defaults to POST
+	Method string `json:"method,omitempty"`
+	Body   string `json:"body,omitempty"`
+}
+
+// ActionResult is the outcome of an executed action
+type ActionResult struct {
+	Action string   `json:"action"`
+	Files  []string `json:"files"`
+	Error  string   `json:"error,omitempty"`
+}
+
+// ParseReloadAction parses an RPC action in the form 'pattern=command'
+func ParseReloadAction(action string) (ReloadAction, error) {
+	pattern, command, found := strings.Cut(action, "=")
+	if !found || strings.TrimSpace(pattern) == "" || strings.TrimSpace(command) == "" {
+		return ReloadAction{}, fmt.Errorf("invalid reload action '%s', expected 'pattern=command'", action)
+	}
+
+	result := ReloadAction{Files: []string{strings.TrimSpace(pattern)}, RPC: strings.TrimSpace(command)}
+	return result, result.Validate()
+}
+
+// ParseReloadActions parses the YAML list of the ActionsKey. Exec and HTTP actions run anything
+// the authors of the templates declare, so they are rejected unless allowExec is set.
+func ParseReloadActions(content string, allowExec bool) ([]ReloadAction, error) {
+	actions := []ReloadAction{}
+	err := yaml.UnmarshalStrict([]byte(content), &actions)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse %s: %w", ActionsKey, err)
+	}
+
+	for i := range actions {
+		err = actions[i].Validate()
+		if err != nil {
+			return nil, fmt.Errorf("invalid action %d in %s: %w", i+1, ActionsKey, err)
+		}
+
+		if !allowExec && (len(actions[i].Exec) > 0 || actions[i].HTTP != nil) {
+			return nil, fmt.Errorf("invalid action %d in %s: exec and http actions are not allowed by the controller", i+1, ActionsKey)
+		}
+	}
+
+	return actions, nil
+}
+
+// Validate checks the patterns, and that exactly one kind of action is set
+func (action *ReloadAction) Validate() error {
+	if len(action.Files) == 0 {
+		return fmt.Errorf("no files are given")
+	}
+
+	for _, pattern := range action.Files {
+		if _, err := filepath.Match(pattern, ""); err != nil {
+			return fmt.Errorf("invalid pattern '%s': %w", pattern, err)
+		}
+	}
+
+	kinds := 0
+	for _, set := range []bool{action.RPC != "", action.Signal != nil, len(action.Exec) > 0, action.HTTP != nil, action.Restart != nil} {
+		if set {
+			kinds++
+		}
+	}
+
+	if kinds != 1 {
+		return fmt.Errorf("exactly one of rpc, signal, exec, http or restart must be set")
+	}
+
+	if action.Signal != nil {
+		if _, err := parseSignal(action.Signal.Signal, syscall.SIGHUP); err != nil {
+			return err
+		}
+	}
+
+	if action.Restart != nil {
+		if _, err := parseSignal(action.Restart.Signal, syscall.SIGTERM); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// String describes the action for logs and results
+func (action *ReloadAction) String() string {
+	switch {
+	case action.RPC != "":
+		return "rpc " + action.RPC
+	case action.Signal != nil:
+		return "signal " + action.Signal.Process
+	case len(action.Exec) > 0:
+		return "exec " + strings.Join(action.Exec, " ")
+	case action.HTTP != nil:
+		return "http " + action.HTTP.URL
+	case action.Restart != nil:
+		return "restart " + action.Restart.Process
+	}
+
+	return "none"
+}
+
+// Matches returns the files matching one of the patterns of the action.
+// A pattern matches the path of the file, or its base name.
+func (action *ReloadAction) Matches(files []string) []string {
+	matches := []string{}
+	for _, file := range files {
+		for _, pattern := range action.Files {
+			matched, _ := filepath.Match(pattern, file)
+			if !matched {
+				matched, _ = filepath.Match(pattern, filepath.Base(file))
+			}
+
+			if matched {
+				matches = append(matches, file)
+				break
+			}
+		}
+	}
+
+	return matches
+}
+
+// changedFiles returns the sorted names of all files which differ between previous and current
+func changedFiles(previous map[string][]byte, current map[string][]byte) []string {
+	changed := []string{}
+
+	for name, content := range current {
+		if old, exists := previous[name]; !exists || !bytes.Equal(old, content) {
+			changed = append(changed, name)
+		}
+	}
+
+	for name := range previous {
+		if _, exists := current[name]; !exists {
+			changed = append(changed, name)
+		}
+	}
+
+	sort.Strings(changed)
+	return changed
+}
+
+// reload executes all actions matching the changed files, in the declared order.
+// It stops at the first failed action.
+func (sidecar *Sidecar) reload(ctx context.Context, actions []ReloadAction, changed []string) ([]ActionResult, error) {
+	log := ctrllog.FromContext(ctx)
+	results := []ActionResult{}
+
+	if sidecar.Options.NoActions {
+		return results, nil
+	}
+
+	for i := range actions {
+		action := &actions[i]
+		files := action.Matches(changed)
+
+		// without RPC address, kamailio is not notified
+		if len(files) == 0 || action.RPC != "" && sidecar.rpc == nil {
+			continue
+		}
+
+		log.Info("Executing reload action", "action", action.String(), "files", files)
+
+		err := sidecar.execute(ctx, action, files)
+		result := ActionResult{Action: action.String(), Files: files}
+		if err != nil {
+			result.Error = err.Error()
+		}
+		results = append(results, result)
+
+		if err != nil {
+			return results, fmt.Errorf("reload action '%s' failed: %w", action.String(), err)
+		}
+	}
+
+	return results, nil
+}
+
+func (sidecar *Sidecar) execute(ctx context.Context, action *ReloadAction, files []string) error {
+	switch {
+	case action.RPC != "":
+		delay := sidecar.Options.RPCRetryDelay
+		if delay == 0 {
+			delay = time.Second
+		}
+
+		method, params := kamailio.ParseCommand(action.RPC)
+		_, err := sidecar.rpc.CallWithRetry(ctx, sidecar.Options.RPCRetries, delay, method, params...)
+		return err
+
+	case action.Signal != nil:
+		signal, _ := parseSignal(action.Signal.Signal, syscall.SIGHUP)
+		return signalProcess(action.Signal.Process, signal)
+
+	case action.Restart != nil:
+		signal, _ := parseSignal(action.Restart.Signal, syscall.SIGTERM)
+		return signalProcess(action.Restart.Process, signal)
+
+	case len(action.Exec) > 0:
+		ctx, cancel := context.WithTimeout(ctx, actionTimeout)
+		defer cancel()
+
+		cmd := exec.CommandContext(ctx, action.Exec[0], action.Exec[1:]...)
+		cmd.Dir = sidecar.Options.ConfigDirectory
+		cmd.Env = append(os.Environ(),
+			"KASICO_CONFIG_DIR="+sidecar.Options.ConfigDirectory,
+			"KASICO_CHANGED_FILES="+strings.Join(files, " "))
+
+		output, err := cmd.CombinedOutput()
+		if err != nil {
+			return fmt.Errorf("%w: %s", err, strings.TrimSpace(string(output)))
+		}
+		return nil
+
+	case action.HTTP != nil:
+		return callHTTP(ctx, action.HTTP)
+	}
+
+	return fmt.Errorf("no action is set")
+}
+
+func callHTTP(ctx context.Context, action *HTTPAction) error {
+	ctx, cancel := context.WithTimeout(ctx, actionTimeout)
+	defer cancel()
+
+	method := action.Method
+	if method == "" {
+		method = http.MethodPost
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, action.URL, strings.NewReader(action.Body))
+	if err != nil {
+		return err
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("unexpected status %s", resp.Status)
+	}
+
+	return nil
+}
+
+var signalNames = map[string]syscall.Signal{
+	"HUP":  syscall.SIGHUP,
+	"INT":  syscall.SIGINT,
+	"QUIT": syscall.SIGQUIT,
+	"KILL": syscall.SIGKILL,
+	"USR1": syscall.SIGUSR1,
+	"USR2": syscall.SIGUSR2,
+	"TERM": syscall.SIGTERM,
+}
+
+// parseSignal parses a signal like SIGHUP, HUP or 1
+func parseSignal(name string, defaultSignal syscall.Signal) (syscall.Signal, error) {
+	if name == "" {
+		return defaultSignal, nil
+	}
+
+	if number, err := strconv.Atoi(name); err == nil {
+		return syscall.Signal(number), nil
+	}
+
+	signal, found := signalNames[strings.TrimPrefix(strings.ToUpper(name), "SIG")]
+	if !found {
+		return 0, fmt.Errorf("unknown signal '%s'", name)
+	}
+
+	return signal, nil
+}
+
+// signalProcess sends the signal to all processes with the name, found in /proc.
+// To see the processes of other containers, the pod needs 'shareProcessNamespace'.
+func signalProcess(name string, signal syscall.Signal) error {
+	entries, err := os.ReadDir("/proc")
+	if err != nil {
+		return err
+	}
+
+	signaled := 0
+	for _, entry := range entries {
+		pid, err := strconv.Atoi(entry.Name())
+		if err != nil || pid == os.Getpid() {
+			continue
+		}
+
+		comm, err := os.ReadFile(filepath.Join("/proc", entry.Name(), "comm"))
+		if err != nil || strings.TrimSpace(string(comm)) != name {
+			continue
+		}
+
+		err = syscall.Kill(pid, signal)
+		if err != nil {
+			return fmt.Errorf("unable to signal process %d: %w", pid, err)
+		}
+		signaled++
+	}
+
+	if signaled == 0 {
+		return fmt.Errorf("no process '%s' found", name)
+	}
+
+	return nil
+}
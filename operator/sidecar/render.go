@@ -0,0 +1,230 @@
+package sidecar
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strings"
+	"text/template"
+
+	"github.com/world-direct/kasico/operator/controllers"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// Inputs are the contents the kamailio configuration is rendered from
+type Inputs struct {
+	// Templates are the keys of the template ConfigMap
+	Templates map[string]string
+
+	// RoutingData is the content of routing-data.json
+	RoutingData string
+
+	// Secrets are the keys of the template Secrets, available as .Secrets
+	Secrets map[string]string
+}
+
+// Hash returns a hash over all inputs, used to skip rendering if nothing has changed.
+// The secret values are hashed on their own, so they are not part of the hashed content.
+func (inputs *Inputs) Hash() string {
+	items := make(map[string]string, len(inputs.Templates)+len(inputs.Secrets)+1)
+	for name, content := range inputs.Templates {
+		items["templates/"+name] = content
+	}
+
+	for name, value := range inputs.Secrets {
+		sum := sha256.Sum256([]byte(value))
+		items["secrets/"+name] = hex.EncodeToString(sum[:])
+	}
+
+	items["routing-data/"+controllers.Name_RouningDataJson] = inputs.RoutingData
+	return controllers.HashStringMap(items)
+}
+
+// The keys of the template ConfigMaps follow these naming rules:
+//
+//	_helpers.tpl          a partial: its 'define' blocks can be used by all templates, it is not written
+//	routes.py             rendered to routes.py
+//	tls__server.pem       '__' separates directories, as ConfigMap keys can't contain '/': tls/server.pem
+//	logo.png.static       written verbatim without rendering, as logo.png
+const (
+	partialPrefix = "_"
+	directorySep  = "__"
+	staticSuffix  = ".static"
+)
+
+// TemplateData returns the templates of a template ConfigMap. The keys of the binaryData
+// are static files, which are written verbatim.
+func TemplateData(cm *corev1.ConfigMap) (map[string]string, error) {
+	templates := make(map[string]string, len(cm.Data)+len(cm.BinaryData))
+	for key, content := range cm.Data {
+		templates[key] = content
+	}
+
+	for key, content := range cm.BinaryData {
+		name := key
+		if !strings.HasSuffix(name, staticSuffix) {
+			name += staticSuffix
+		}
+
+		if _, exists := templates[name]; exists {
+			return nil, fmt.Errorf("the key %s of the binaryData of the ConfigMap %s conflicts with its data", key, cm.Name)
+		}
+		templates[name] = string(content)
+	}
+
+	return templates, nil
+}
+
+// MergeLayers merges the templates or secrets in order, keys of later layers override earlier ones
+func MergeLayers(layers ...map[string]string) map[string]string {
+	merged := map[string]string{}
+	for _, layer := range layers {
+		for name, content := range layer {
+			merged[name] = content
+		}
+	}
+
+	return merged
+}
+
+// OutputPath returns the path of the file rendered from the template key, and false for partials
+func OutputPath(key string) (string, bool, error) {
+	if strings.HasPrefix(key, partialPrefix) {
+		return "", false, nil
+	}
+
+	path := strings.TrimSuffix(strings.ReplaceAll(key, directorySep, "/"), staticSuffix)
+	for _, part := range strings.Split(path, "/") {
+		if part == "" || part == "." || part == ".." || strings.HasPrefix(part, "..") {
+			return "", false, fmt.Errorf("invalid template name %s", key)
+		}
+	}
+
+	return path, true, nil
+}
+
+// Rendered is the result of Render
+type Rendered struct {
+	RoutingData *controllers.RoutingData
+
+	// Files is the rendered content by output path
+	Files map[string][]byte
+
+	// Sensitive contains the output paths of the files depending on the Secrets
+	Sensitive map[string]bool
+
+	// Actions are declared by the ActionsKey of the templates, nil if it is missing
+	Actions []ReloadAction
+}
+
+// Render parses the routing data, and executes every template with it, and the Secrets.
+// To find the files depending on the Secrets, they are rendered a second time with
+// redacted secrets, and compared. Exec and HTTP actions are rejected, unless allowExecActions is set.
+func Render(inputs *Inputs, allowExecActions bool) (*Rendered, error) {
+	routingData, err := controllers.UnmarshalRoutingData(inputs.RoutingData)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse %s: %w", controllers.Name_RouningDataJson, err)
+	}
+
+	routingData.Secrets = inputs.Secrets
+	if routingData.Secrets == nil {
+		routingData.Secrets = map[string]string{}
+	}
+
+	files, err := renderTemplates(inputs.Templates, routingData)
+	if err != nil {
+		return nil, err
+	}
+
+	rendered := &Rendered{RoutingData: routingData, Files: files, Sensitive: map[string]bool{}}
+	if actions, declared := inputs.Templates[ActionsKey]; declared {
+		rendered.Actions, err = ParseReloadActions(actions, allowExecActions)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if len(inputs.Secrets) == 0 {
+		return rendered, nil
+	}
+
+	redacted := *routingData
+	redacted.Secrets = map[string]string{}
+	for name := range inputs.Secrets {
+		redacted.Secrets[name] = "redacted:" + name
+	}
+
+	// a template failing with the redacted secrets depends on them as well
+	redactedFiles, _ := renderTemplates(inputs.Templates, &redacted)
+	for path, content := range files {
+		if redactedContent, exists := redactedFiles[path]; !exists || !bytes.Equal(content, redactedContent) {
+			rendered.Sensitive[path] = true
+		}
+	}
+
+	return rendered, nil
+}
+
+// renderTemplates executes the templates with the data, and returns the content by output path
+func renderTemplates(templates map[string]string, data *controllers.RoutingData) (map[string][]byte, error) {
+	var err error
+
+	names := make([]string, 0, len(templates))
+	for name := range templates {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	// the partials are parsed first, so every template can use their definitions
+	partials := template.New("").Funcs(FuncMap()).Option("missingkey=error")
+	for _, name := range names {
+		if strings.HasPrefix(name, partialPrefix) && name != ActionsKey {
+			_, err = partials.New(name).Parse(templates[name])
+			if err != nil {
+				return nil, fmt.Errorf("unable to parse partial %s: %w", name, err)
+			}
+		}
+	}
+
+	files := make(map[string][]byte, len(names))
+	for _, name := range names {
+		path, rendered, err := OutputPath(name)
+		if err != nil {
+			return nil, err
+		}
+
+		if !rendered {
+			continue
+		}
+
+		if _, exists := files[path]; exists {
+			return nil, fmt.Errorf("template %s renders to %s, which is already rendered by another template", name, path)
+		}
+
+		if strings.HasSuffix(name, staticSuffix) {
+			files[path] = []byte(templates[name])
+			continue
+		}
+
+		tmpl, err := partials.Clone()
+		if err != nil {
+			return nil, err
+		}
+
+		tmpl, err = tmpl.New(name).Parse(templates[name])
+		if err != nil {
+			return nil, fmt.Errorf("unable to parse template %s: %w", name, err)
+		}
+
+		buffer := &bytes.Buffer{}
+		err = tmpl.Execute(buffer, data)
+		if err != nil {
+			return nil, fmt.Errorf("unable to render template %s: %w", name, err)
+		}
+
+		files[path] = buffer.Bytes()
+	}
+
+	return files, nil
+}
@@ -0,0 +1,144 @@
+package sidecar
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/world-direct/kasico/operator/controllers"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// PodReporter annotates the pod of the sidecar with the applied routing-data, and the error
+// of a rejected configuration, so the operator can aggregate them in the RouterInstance status.
+type PodReporter struct {
+	Client client.Client
+	Pod    types.NamespacedName
+}
+
+func (reporter *PodReporter) Report(ctx context.Context, result *Result) error {
+	// the error annotation is removed by the merge patch with null
+	annotations := map[string]interface{}{
+		controllers.Name_AnnotationConfigError: nil,
+	}
+
+	if result.Err != nil {
+		message := fmt.Sprintf("%s: generation %d rejected: %v", result.Reason, result.Generation, result.Err)
+		if result.RolledBack {
+			message += " (rolled back to the last known-good configuration)"
+		}
+		annotations[controllers.Name_AnnotationConfigError] = message
+	}
+
+	// the applied annotations describe the active configuration, which did not change if it was not activated
+	if result.Activated {
+		annotations[controllers.Name_AnnotationAppliedHash] = result.RoutingDataHash
+		annotations[controllers.Name_AnnotationAppliedGeneration] = strconv.Itoa(result.Generation)
+	}
+
+	patch, err := json.Marshal(map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"annotations": annotations,
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	pod := &corev1.Pod{}
+	pod.Namespace, pod.Name = reporter.Pod.Namespace, reporter.Pod.Name
+	return reporter.Client.Patch(ctx, pod, client.RawPatch(types.MergePatchType, patch))
+}
+
+// Status is the state of the sidecar, served by the StatusServer
+type Status struct {
+	// Hash of the active Inputs
+	Hash string `json:"hash,omitempty"`
+
+	// RoutingDataHash and Generation of the active routing-data
+	RoutingDataHash string `json:"routingDataHash,omitempty"`
+	Generation      int    `json:"generation,omitempty"`
+
+	AppliedAt *time.Time `json:"appliedAt,omitempty"`
+
+	// Actions are the reload actions executed by the last Apply
+	Actions []ActionResult `json:"actions,omitempty"`
+
+	// LastError is the error of the last Apply, empty if it succeeded
+	LastError   string     `json:"lastError,omitempty"`
+	LastErrorAt *time.Time `json:"lastErrorAt,omitempty"`
+}
+
+// StatusServer serves the Status as JSON on /status. It implements 'manager.Runnable'.
+type StatusServer struct {
+	Address string
+
+	mu     sync.Mutex
+	status Status
+}
+
+func (server *StatusServer) Report(ctx context.Context, result *Result) error {
+	server.mu.Lock()
+	defer server.mu.Unlock()
+
+	now := time.Now()
+
+	if result.Activated {
+		server.status.Hash = result.Hash
+		server.status.RoutingDataHash = result.RoutingDataHash
+		server.status.Generation = result.Generation
+		server.status.AppliedAt = &now
+	}
+
+	if result.Activated || result.RolledBack {
+		server.status.Actions = result.Actions
+	}
+
+	if result.Err != nil {
+		server.status.LastError = result.Err.Error()
+		server.status.LastErrorAt = &now
+	} else {
+		server.status.LastError = ""
+		server.status.LastErrorAt = nil
+	}
+
+	return nil
+}
+
+// Status returns a copy of the current status
+func (server *StatusServer) Status() Status {
+	server.mu.Lock()
+	defer server.mu.Unlock()
+
+	return server.status
+}
+
+func (server *StatusServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(server.Status())
+}
+
+func (server *StatusServer) Start(ctx context.Context) error {
+	mux := http.NewServeMux()
+	mux.Handle("/status", server)
+
+	httpServer := &http.Server{Addr: server.Address, Handler: mux}
+
+	go func() {
+		<-ctx.Done()
+		httpServer.Close()
+	}()
+
+	err := httpServer.ListenAndServe()
+	if err == http.ErrServerClosed {
+		return nil
+	}
+
+	return err
+}
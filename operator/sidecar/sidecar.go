@@ -0,0 +1,346 @@
+// Package sidecar implements the kasico controller, which runs beside kamailio in
+// each router pod. It renders the templates with the routing-data into the
+// configuration directory of kamailio, every time one of them changes.
+package sidecar
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/world-direct/kasico/operator/controllers"
+	"github.com/world-direct/kasico/operator/kamailio"
+	"github.com/world-direct/kasico/operator/routingdb"
+	ctrllog "sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// Options configures the Sidecar
+type Options struct {
+	// ConfigDirectory is the directory the rendered files are written to
+	ConfigDirectory string
+
+	// SqliteFile is the path of the routing database, if empty no database is built
+	SqliteFile string
+
+	// RPCAddress is the address of the kamailio JSON-RPC interface, see kamailio.NewClient.
+	// If empty, kamailio is not notified about changes.
+	RPCAddress string
+
+	// ReloadActions are executed in order, after matching files have been changed.
+	// They are replaced by the actions declared in the ActionsKey of the templates.
+	ReloadActions []ReloadAction
+
+	// AllowExecActions allows the exec and http actions declared in the templates
+	AllowExecActions bool
+
+	// NoActions disables the reload actions, e.g. if the configuration is rendered before kamailio is started
+	NoActions bool
+
+	// RPCRetries is the number of retries of a failed reload command
+	RPCRetries int
+
+	// RPCRetryDelay is the delay before the first retry, defaults to one second
+	RPCRetryDelay time.Duration
+
+	// CheckCommand validates the staged configuration before it is activated, e.g.
+	// 'kamailio -c -f {dir}/kamailio.cfg'. {dir} is replaced by the staging directory.
+	CheckCommand string
+
+	// PythonCheck is the python interpreter used to check the syntax of the rendered *.py
+	// files (KEMI scripts). If empty, they are not checked.
+	PythonCheck string
+}
+
+// The delay before failed inputs are applied again, doubled with every attempt up to retryMaxDelay
+const retryBaseDelay = 5 * time.Second
+const retryMaxDelay = 5 * time.Minute
+
+// appliedConfig is a configuration which has been activated and reloaded successfully
+type appliedConfig struct {
+	rendered *Rendered
+	dataHash string
+	actions  []ReloadAction
+}
+
+// Sidecar renders the Inputs into the ConfigDirectory
+type Sidecar struct {
+	Options Options
+
+	// Reporter is notified about the result of each Apply, if set
+	Reporter Reporter
+
+	rpc *kamailio.Client
+
+	mu        sync.Mutex
+	lastHash  string
+	lastGood  *appliedConfig
+	lastFiles map[string][]byte
+
+	// unreloaded are the changed files, which have been activated, but not reloaded by kamailio
+	unreloaded []string
+
+	// failures counts the failed attempts to apply the inputs with failedHash, 0 once they are done
+	failedHash string
+	failures   int
+}
+
+func New(options Options) (*Sidecar, error) {
+	sidecar := &Sidecar{Options: options}
+
+	if options.RPCAddress != "" {
+		rpc, err := kamailio.NewClient(options.RPCAddress)
+		if err != nil {
+			return nil, err
+		}
+		sidecar.rpc = rpc
+	}
+
+	return sidecar, nil
+}
+
+// Apply renders the inputs into a staging directory, validates them, and activates them in the
+// ConfigDirectory. If the inputs have already been applied, or failed to render or validate,
+// nothing is done until they change. Other failures, like reloading kamailio, are retried after
+// RetryDelay. If reloading kamailio fails, the last known-good configuration is restored.
+// It returns true if the files have been activated.
+func (sidecar *Sidecar) Apply(ctx context.Context, inputs *Inputs) (bool, error) {
+	sidecar.mu.Lock()
+	defer sidecar.mu.Unlock()
+
+	log := ctrllog.FromContext(ctx)
+
+	hash := inputs.Hash()
+	if hash == sidecar.lastHash {
+		log.V(1).Info("Inputs have not been changed, skipping", "hash", hash)
+		return false, nil
+	}
+
+	result := sidecar.apply(ctx, log, inputs, hash)
+	if sidecar.lastHash == hash {
+		sidecar.failures = 0
+	} else {
+		if sidecar.failedHash != hash {
+			sidecar.failedHash, sidecar.failures = hash, 0
+		}
+		sidecar.failures++
+		log.Info("Unable to apply the configuration, retrying", "hash", hash, "delay", sidecar.retryDelay().String())
+	}
+
+	if sidecar.Reporter != nil {
+		err := sidecar.Reporter.Report(ctx, result)
+		if err != nil {
+			log.Error(err, "Unable to report the result")
+		}
+	}
+
+	return result.Activated, result.Err
+}
+
+// RetryDelay returns the delay, after which the last inputs should be applied again,
+// or 0 if they don't need to be retried
+func (sidecar *Sidecar) RetryDelay() time.Duration {
+	sidecar.mu.Lock()
+	defer sidecar.mu.Unlock()
+
+	return sidecar.retryDelay()
+}
+
+func (sidecar *Sidecar) retryDelay() time.Duration {
+	if sidecar.failures == 0 {
+		return 0
+	}
+
+	delay := retryBaseDelay
+	for i := 1; i < sidecar.failures && delay < retryMaxDelay; i++ {
+		delay *= 2
+	}
+	if delay > retryMaxDelay {
+		delay = retryMaxDelay
+	}
+	return delay
+}
+
+// apply sets lastHash, if the inputs are done: applied, or failing to render or validate,
+// which would fail again until they change
+func (sidecar *Sidecar) apply(ctx context.Context, log logr.Logger, inputs *Inputs, hash string) *Result {
+	result := &Result{Hash: hash}
+
+	log.Info("Rendering configuration", "hash", hash, "directory", sidecar.Options.ConfigDirectory)
+
+	rendered, err := Render(inputs, sidecar.Options.AllowExecActions)
+	if err != nil {
+		sidecar.lastHash = hash
+		result.Reason, result.Err = Reason_RenderFailed, err
+		return result
+	}
+	result.Generation = rendered.RoutingData.Generation
+	result.RoutingDataHash, err = controllers.HashRoutingData(rendered.RoutingData)
+	if err != nil {
+		sidecar.lastHash = hash
+		result.Reason, result.Err = Reason_RenderFailed, err
+		return result
+	}
+
+	stagingDir, err := Stage(sidecar.Options.ConfigDirectory, rendered.Files, rendered.Sensitive)
+	if err != nil {
+		result.Reason, result.Err = Reason_RenderFailed, err
+		return result
+	}
+
+	err = sidecar.validate(ctx, stagingDir, rendered.Files)
+	if err != nil {
+		Discard(stagingDir)
+		log.Info("Configuration rejected, keeping the active one", "hash", hash, "error", err.Error())
+		sidecar.lastHash = hash
+		result.Reason, result.Err = Reason_ValidationFailed, err
+		return result
+	}
+
+	// a fresh process compares with the files already active, e.g. rendered by the generator
+	// before kamailio has been started, so kamailio is only reloaded if they differ
+	if sidecar.lastFiles == nil {
+		sidecar.lastFiles = readActiveFiles(sidecar.Options.ConfigDirectory, rendered.Files)
+	}
+
+	err = Activate(sidecar.Options.ConfigDirectory, stagingDir, rendered.Files)
+	if err != nil {
+		result.Reason, result.Err = Reason_RenderFailed, err
+		return result
+	}
+
+	result.Activated = true
+
+	// the content is never logged, as it may contain secrets
+	for name := range rendered.Files {
+		log.V(1).Info("Written", "file", filepath.Join(sidecar.Options.ConfigDirectory, name), "sensitive", rendered.Sensitive[name])
+	}
+
+	// the database only depends on the routing-data, not on the templates
+	applied := &appliedConfig{rendered: rendered, dataHash: (&Inputs{RoutingData: inputs.RoutingData}).Hash(), actions: rendered.Actions}
+	if applied.actions == nil {
+		applied.actions = sidecar.Options.ReloadActions
+	}
+
+	changed, err := sidecar.activate(log, applied)
+	if err != nil {
+		result.Reason, result.Err = Reason_RenderFailed, err
+		return result
+	}
+
+	log.Info("Configuration written", "hash", hash, "generation", rendered.RoutingData.Generation)
+
+	// the files activated by a failed attempt are unchanged now, but still have to be reloaded
+	changed = mergeNames(changed, sidecar.unreloaded)
+
+	result.Actions, err = sidecar.reload(ctx, applied.actions, changed)
+	if err != nil {
+		result.Reason, result.Err = Reason_ReloadFailed, err
+		result.RolledBack = sidecar.rollback(ctx, log)
+		result.Activated = !result.RolledBack
+
+		sidecar.unreloaded = nil
+		if !result.RolledBack {
+			sidecar.unreloaded = changed
+		}
+		return result
+	}
+
+	sidecar.unreloaded = nil
+	sidecar.lastGood = applied
+	sidecar.lastHash = hash
+	result.Reason = Reason_Applied
+	return result
+}
+
+// readActiveFiles returns the content of the files in dir, which are also in files
+func readActiveFiles(dir string, files map[string][]byte) map[string][]byte {
+	active := map[string][]byte{}
+	for name := range files {
+		content, err := os.ReadFile(filepath.Join(dir, name))
+		if err == nil {
+			active[name] = content
+		}
+	}
+	return active
+}
+
+// mergeNames returns the sorted union of the names
+func mergeNames(names []string, more []string) []string {
+	set := map[string]bool{}
+	for _, name := range append(append([]string{}, names...), more...) {
+		set[name] = true
+	}
+
+	merged := []string{}
+	for name := range set {
+		merged = append(merged, name)
+	}
+	sort.Strings(merged)
+	return merged
+}
+
+// activate tracks the activated files and builds the database, and returns the names of the changed files
+func (sidecar *Sidecar) activate(log logr.Logger, applied *appliedConfig) ([]string, error) {
+	changed := changedFiles(sidecar.lastFiles, applied.rendered.Files)
+	sidecar.lastFiles = applied.rendered.Files
+
+	if sidecar.Options.SqliteFile != "" {
+		built, err := sidecar.buildDatabase(log, applied.dataHash, applied.rendered.RoutingData)
+		if err != nil {
+			return nil, err
+		}
+
+		if built {
+			changed = append(changed, sidecar.Options.SqliteFile)
+		}
+	}
+
+	return changed, nil
+}
+
+// rollback restores the last known-good configuration, and returns true if it succeeded
+func (sidecar *Sidecar) rollback(ctx context.Context, log logr.Logger) bool {
+	if sidecar.lastGood == nil {
+		log.Info("No known-good configuration to roll back to")
+		return false
+	}
+
+	log.Info("Rolling back to the last known-good configuration", "generation", sidecar.lastGood.rendered.RoutingData.Generation)
+
+	err := WriteAtomic(sidecar.Options.ConfigDirectory, sidecar.lastGood.rendered.Files, sidecar.lastGood.rendered.Sensitive)
+	if err != nil {
+		log.Error(err, "Unable to restore the files")
+		return false
+	}
+
+	changed, err := sidecar.activate(log, sidecar.lastGood)
+	if err != nil {
+		log.Error(err, "Unable to restore the routing database")
+		return false
+	}
+
+	_, err = sidecar.reload(ctx, sidecar.lastGood.actions, changed)
+	if err != nil {
+		log.Error(err, "Unable to reload the restored configuration")
+		return false
+	}
+
+	return true
+}
+
+func (sidecar *Sidecar) buildDatabase(log logr.Logger, hash string, routingData *controllers.RoutingData) (bool, error) {
+	changed, err := routingdb.Build(sidecar.Options.SqliteFile, routingData, hash)
+	if err != nil {
+		return false, err
+	}
+
+	if changed {
+		log.Info("Routing database written", "file", sidecar.Options.SqliteFile)
+	}
+
+	return changed, nil
+}
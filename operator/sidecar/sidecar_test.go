@@ -0,0 +1,548 @@
+package sidecar
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/yaml"
+)
+
+const testRoutingData = `{"UDPPort": 5060, "Generation": 3, "Rules": [{"Domain": "a.example.org", "Headnumber": "+431", "Owner": "default/a", "Backend": "s1.default"}]}`
+
+func readFile(t *testing.T, path string) string {
+	content, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	return string(content)
+}
+
+func TestRender(t *testing.T) {
+	rendered, err := Render(&Inputs{
+		Templates: map[string]string{
+			"kamailio.cfg": "listen=udp:0.0.0.0:{{.UDPPort}}",
+			"rules.txt":    "{{range .Rules}}{{.Headnumber}}={{.Backend}}{{end}}",
+		},
+		RoutingData: testRoutingData,
+	}, false)
+
+	assert.NoError(t, err)
+	assert.Equal(t, "listen=udp:0.0.0.0:5060", string(rendered.Files["kamailio.cfg"]))
+	assert.Equal(t, "+431=s1.default", string(rendered.Files["rules.txt"]))
+}
+
+func TestRender_Errors(t *testing.T) {
+	_, err := Render(&Inputs{Templates: map[string]string{"a": "{{.UDPPort"}, RoutingData: testRoutingData}, false)
+	assert.Error(t, err)
+
+	_, err = Render(&Inputs{Templates: map[string]string{"a": "{{.Unknown}}"}, RoutingData: testRoutingData}, false)
+	assert.Error(t, err)
+
+	_, err = Render(&Inputs{Templates: map[string]string{"a": "a"}, RoutingData: "{"}, false)
+	assert.Error(t, err)
+}
+
+func TestWriteAtomic(t *testing.T) {
+	dir := t.TempDir()
+
+	err := WriteAtomic(dir, map[string][]byte{"a.cfg": []byte("a1"), "b.cfg": []byte("b1")}, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, "a1", readFile(t, filepath.Join(dir, "a.cfg")))
+	assert.Equal(t, "b1", readFile(t, filepath.Join(dir, "b.cfg")))
+
+	err = WriteAtomic(dir, map[string][]byte{"a.cfg": []byte("a2")}, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, "a2", readFile(t, filepath.Join(dir, "a.cfg")))
+
+	_, err = os.Lstat(filepath.Join(dir, "b.cfg"))
+	assert.True(t, os.IsNotExist(err))
+
+	// only the current revision is kept
+	entries, err := filepath.Glob(filepath.Join(dir, revisionDirPrefix+"*"))
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(entries))
+}
+
+func TestApply_SkipsUnchanged(t *testing.T) {
+	dir := t.TempDir()
+	sidecar, err := New(Options{ConfigDirectory: dir, SqliteFile: filepath.Join(dir, "routing.db")})
+	assert.NoError(t, err)
+	inputs := &Inputs{Templates: map[string]string{"a.cfg": "{{.Generation}}"}, RoutingData: testRoutingData}
+
+	written, err := sidecar.Apply(context.Background(), inputs)
+	assert.NoError(t, err)
+	assert.True(t, written)
+	assert.Equal(t, "3", readFile(t, filepath.Join(dir, "a.cfg")))
+	assert.FileExists(t, filepath.Join(dir, "routing.db"))
+
+	written, err = sidecar.Apply(context.Background(), inputs)
+	assert.NoError(t, err)
+	assert.False(t, written)
+}
+
+func TestApply_Reload(t *testing.T) {
+	methods := []string{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		req := map[string]interface{}{}
+		json.NewDecoder(r.Body).Decode(&req)
+		methods = append(methods, req["method"].(string))
+		w.Write([]byte(`{"jsonrpc": "2.0", "result": "ok", "id": 1}`))
+	}))
+	defer server.Close()
+
+	dir := t.TempDir()
+	sidecar, err := New(Options{
+		ConfigDirectory: dir,
+		RPCAddress:      server.URL,
+		ReloadActions: []ReloadAction{
+			{Files: []string{"dispatcher.list"}, RPC: "dispatcher.reload"},
+			{Files: []string{"*.py"}, RPC: "app_python3.reload"},
+		},
+	})
+	assert.NoError(t, err)
+
+	templates := map[string]string{"dispatcher.list": "{{.Generation}}", "kamailio.py": "pass"}
+	_, err = sidecar.Apply(context.Background(), &Inputs{Templates: templates, RoutingData: testRoutingData})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"dispatcher.reload", "app_python3.reload"}, methods)
+
+	// only the dispatcher list depends on the routing-data
+	methods = []string{}
+	_, err = sidecar.Apply(context.Background(), &Inputs{Templates: templates, RoutingData: strings.Replace(testRoutingData, `"Generation": 3`, `"Generation": 4`, 1)})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"dispatcher.reload"}, methods)
+}
+
+func TestParseReloadAction(t *testing.T) {
+	action, err := ParseReloadAction("routing-data.sqlite = htable.reload routes")
+	assert.NoError(t, err)
+	assert.Equal(t, ReloadAction{Files: []string{"routing-data.sqlite"}, RPC: "htable.reload routes"}, action)
+
+	_, err = ParseReloadAction("dispatcher.reload")
+	assert.Error(t, err)
+}
+
+func TestParseReloadActions(t *testing.T) {
+	actions, err := ParseReloadActions(`
+- files: [dispatcher.list]
+  rpc: dispatcher.reload
+- files: ["tls/*"]
+  signal: {process: kamailio, signal: SIGUSR1}
+- files: [kamailio.cfg]
+  restart: {process: kamailio}
+`, false)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, len(actions))
+	assert.Equal(t, "signal kamailio", actions[1].String())
+	assert.Equal(t, []string{"tls/server.pem"}, actions[1].Matches([]string{"kamailio.cfg", "tls/server.pem"}))
+	assert.Equal(t, []string{"/data/routing-data.sqlite"}, (&ReloadAction{Files: []string{"routing-data.sqlite"}}).Matches([]string{"/data/routing-data.sqlite"}))
+	assert.Equal(t, []string{"/data/routing-data.sqlite"}, (&ReloadAction{Files: []string{"/data/*.sqlite"}}).Matches([]string{"/data/routing-data.sqlite"}))
+
+	_, err = ParseReloadActions("- files: [a.cfg]\n  rpc: cfg.reload\n  exec: [true]", true)
+	assert.Error(t, err)
+
+	_, err = ParseReloadActions("- files: [a.cfg]\n  signal: {process: kamailio, signal: SIGFOO}", false)
+	assert.Error(t, err)
+
+	// exec and http actions need --allow-exec-actions
+	_, err = ParseReloadActions("- files: [a.cfg]\n  exec: [true]", false)
+	assert.EqualError(t, err, "invalid action 1 in _actions.yaml: exec and http actions are not allowed by the controller")
+	_, err = ParseReloadActions("- files: [a.cfg]\n  http: {url: \"http://127.0.0.1/reload\"}", false)
+	assert.Error(t, err)
+	_, err = ParseReloadActions("- files: [a.cfg]\n  exec: [true]", true)
+	assert.NoError(t, err)
+}
+
+func TestApply_DeclaredActions(t *testing.T) {
+	calls := []string{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls = append(calls, r.Method+" "+r.URL.Path)
+	}))
+	defer server.Close()
+
+	dir := t.TempDir()
+	reporter := &testReporter{}
+	sidecar, err := New(Options{
+		ConfigDirectory:  dir,
+		ReloadActions:    []ReloadAction{{Files: []string{"*"}, RPC: "cfg.reload"}},
+		AllowExecActions: true,
+	})
+	assert.NoError(t, err)
+	sidecar.Reporter = reporter
+
+	templates := map[string]string{
+		ActionsKey: `
+- files: ["*.cfg"]
+  exec: [sh, -c, 'echo "$KASICO_CHANGED_FILES" > changed']
+- files: [acl.cfg]
+  http: {url: "` + server.URL + `/reload"}
+`,
+		"a.cfg":   "{{.Generation}}",
+		"acl.cfg": "allow",
+	}
+	_, err = sidecar.Apply(context.Background(), &Inputs{Templates: templates, RoutingData: testRoutingData})
+	assert.NoError(t, err)
+
+	// the declared actions replace the options, and are not rendered
+	assert.Equal(t, "a.cfg acl.cfg\n", readFile(t, filepath.Join(dir, "changed")))
+	assert.Equal(t, []string{"POST /reload"}, calls)
+	assert.NoFileExists(t, filepath.Join(dir, ActionsKey))
+
+	result := reporter.results[0]
+	assert.Equal(t, 2, len(result.Actions))
+	assert.Equal(t, []string{"acl.cfg"}, result.Actions[1].Files)
+	assert.Empty(t, result.Actions[1].Error)
+}
+
+func TestApply_ValidationFailed(t *testing.T) {
+	dir := t.TempDir()
+	sidecar, err := New(Options{ConfigDirectory: dir, CheckCommand: "grep -q valid {dir}/a.cfg"})
+	assert.NoError(t, err)
+
+	written, err := sidecar.Apply(context.Background(), &Inputs{Templates: map[string]string{"a.cfg": "valid"}, RoutingData: testRoutingData})
+	assert.NoError(t, err)
+	assert.True(t, written)
+
+	written, err = sidecar.Apply(context.Background(), &Inputs{Templates: map[string]string{"a.cfg": "broken"}, RoutingData: testRoutingData})
+	assert.IsType(t, &ValidationError{}, err)
+	assert.False(t, written)
+	assert.Equal(t, "valid", readFile(t, filepath.Join(dir, "a.cfg")))
+
+	// the staging directory has been removed
+	entries, err := filepath.Glob(filepath.Join(dir, revisionDirPrefix+"*"))
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(entries))
+}
+
+type testReporter struct {
+	results []*Result
+}
+
+func (reporter *testReporter) Report(ctx context.Context, result *Result) error {
+	reporter.results = append(reporter.results, result)
+	return nil
+}
+
+func TestApply_RollbackOnReloadFailure(t *testing.T) {
+	fail := false
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if fail {
+			w.Write([]byte(`{"jsonrpc": "2.0", "error": {"code": 500, "message": "failed"}, "id": 1}`))
+			fail = false
+			return
+		}
+		w.Write([]byte(`{"jsonrpc": "2.0", "result": "ok", "id": 1}`))
+	}))
+	defer server.Close()
+
+	dir := t.TempDir()
+	reporter := &testReporter{}
+	sidecar, err := New(Options{
+		ConfigDirectory: dir,
+		RPCAddress:      server.URL,
+		ReloadActions:   []ReloadAction{{Files: []string{"*"}, RPC: "cfg.reload"}},
+	})
+	assert.NoError(t, err)
+	sidecar.Reporter = reporter
+
+	_, err = sidecar.Apply(context.Background(), &Inputs{Templates: map[string]string{"a.cfg": "good"}, RoutingData: testRoutingData})
+	assert.NoError(t, err)
+
+	fail = true
+	written, err := sidecar.Apply(context.Background(), &Inputs{Templates: map[string]string{"a.cfg": "bad"}, RoutingData: testRoutingData})
+	assert.Error(t, err)
+	assert.False(t, written)
+	assert.Equal(t, "good", readFile(t, filepath.Join(dir, "a.cfg")))
+
+	assert.Equal(t, 2, len(reporter.results))
+	assert.Equal(t, Reason_Applied, reporter.results[0].Reason)
+	assert.Equal(t, Reason_ReloadFailed, reporter.results[1].Reason)
+	assert.True(t, reporter.results[1].RolledBack)
+}
+
+func TestApply_RetryReloadFailure(t *testing.T) {
+	failures := 2
+	methods := []string{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		req := map[string]interface{}{}
+		json.NewDecoder(r.Body).Decode(&req)
+		methods = append(methods, req["method"].(string))
+		if failures > 0 {
+			failures--
+			w.Write([]byte(`{"jsonrpc": "2.0", "error": {"code": 500, "message": "failed"}, "id": 1}`))
+			return
+		}
+		w.Write([]byte(`{"jsonrpc": "2.0", "result": "ok", "id": 1}`))
+	}))
+	defer server.Close()
+
+	dir := t.TempDir()
+	sidecar, err := New(Options{
+		ConfigDirectory: dir,
+		RPCAddress:      server.URL,
+		ReloadActions:   []ReloadAction{{Files: []string{"dispatcher.list"}, RPC: "dispatcher.reload"}},
+	})
+	assert.NoError(t, err)
+	inputs := &Inputs{Templates: map[string]string{"dispatcher.list": "{{.Generation}}"}, RoutingData: testRoutingData}
+
+	// without a known-good configuration, the files stay active, but are reloaded again
+	_, err = sidecar.Apply(context.Background(), inputs)
+	assert.Error(t, err)
+	assert.Equal(t, retryBaseDelay, sidecar.RetryDelay())
+
+	_, err = sidecar.Apply(context.Background(), inputs)
+	assert.Error(t, err)
+	assert.Equal(t, 2*retryBaseDelay, sidecar.RetryDelay())
+
+	_, err = sidecar.Apply(context.Background(), inputs)
+	assert.NoError(t, err)
+	assert.Equal(t, time.Duration(0), sidecar.RetryDelay())
+	assert.Equal(t, []string{"dispatcher.reload", "dispatcher.reload", "dispatcher.reload"}, methods)
+
+	written, err := sidecar.Apply(context.Background(), inputs)
+	assert.NoError(t, err)
+	assert.False(t, written)
+}
+
+func TestApply_ValidationFailedNotRetried(t *testing.T) {
+	dir, checkDir := t.TempDir(), t.TempDir()
+	check := filepath.Join(checkDir, "check.sh")
+	assert.NoError(t, os.WriteFile(check, []byte("echo checked >> "+filepath.Join(checkDir, "checks")+"\nexit 1\n"), 0644))
+	sidecar, err := New(Options{ConfigDirectory: dir, CheckCommand: "sh " + check})
+	assert.NoError(t, err)
+	inputs := &Inputs{Templates: map[string]string{"a.cfg": "broken"}, RoutingData: testRoutingData}
+
+	_, err = sidecar.Apply(context.Background(), inputs)
+	assert.IsType(t, &ValidationError{}, err)
+	assert.Equal(t, time.Duration(0), sidecar.RetryDelay())
+
+	written, err := sidecar.Apply(context.Background(), inputs)
+	assert.NoError(t, err)
+	assert.False(t, written)
+	assert.Equal(t, "checked\n", readFile(t, filepath.Join(checkDir, "checks")))
+}
+
+func TestApply_FreshProcess(t *testing.T) {
+	methods := []string{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		req := map[string]interface{}{}
+		json.NewDecoder(r.Body).Decode(&req)
+		methods = append(methods, req["method"].(string))
+		w.Write([]byte(`{"jsonrpc": "2.0", "result": "ok", "id": 1}`))
+	}))
+	defer server.Close()
+
+	dir := t.TempDir()
+	options := Options{
+		ConfigDirectory: dir,
+		SqliteFile:      filepath.Join(dir, "routing.db"),
+		RPCAddress:      server.URL,
+		ReloadActions: []ReloadAction{
+			{Files: []string{"dispatcher.list"}, RPC: "dispatcher.reload"},
+			{Files: []string{"routing.db"}, RPC: "htable.reload"},
+		},
+	}
+	templates := map[string]string{"dispatcher.list": "{{.Generation}}"}
+
+	// the configuration is rendered by the generator, before kamailio is started
+	generatorOptions := options
+	generatorOptions.NoActions = true
+	generator, err := New(generatorOptions)
+	assert.NoError(t, err)
+	_, err = generator.Apply(context.Background(), &Inputs{Templates: templates, RoutingData: testRoutingData})
+	assert.NoError(t, err)
+
+	// the sidecar starts with the same inputs, so kamailio has already loaded them
+	sidecar, err := New(options)
+	assert.NoError(t, err)
+	written, err := sidecar.Apply(context.Background(), &Inputs{Templates: templates, RoutingData: testRoutingData})
+	assert.NoError(t, err)
+	assert.True(t, written)
+	assert.Empty(t, methods)
+
+	_, err = sidecar.Apply(context.Background(), &Inputs{Templates: templates, RoutingData: strings.Replace(testRoutingData, `"Generation": 3`, `"Generation": 4`, 1)})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"dispatcher.reload", "htable.reload"}, methods)
+}
+
+func TestDirectorySource(t *testing.T) {
+	// WriteAtomic creates the same layout as kubelet for ConfigMap volumes
+	templatesDir, dataDir := t.TempDir(), t.TempDir()
+	assert.NoError(t, WriteAtomic(templatesDir, map[string][]byte{"a.cfg": []byte("{{.Generation}}")}, nil))
+	assert.NoError(t, WriteAtomic(dataDir, map[string][]byte{"routing-data.json": []byte(testRoutingData)}, nil))
+
+	inputs, err := (&DirectorySource{TemplatesDirectories: []string{templatesDir}, RoutingDataDirectory: dataDir}).Load()
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"a.cfg": "{{.Generation}}"}, inputs.Templates)
+	assert.Equal(t, testRoutingData, inputs.RoutingData)
+}
+
+func TestDirectorySource_BinaryData(t *testing.T) {
+	// the keys of the binaryData are written to the volume like the keys of the data
+	logo := string([]byte{0x89, 'P', 'N', 'G', 0xff, 0xfe})
+	templatesDir, dataDir := t.TempDir(), t.TempDir()
+	assert.NoError(t, WriteAtomic(templatesDir, map[string][]byte{"a.cfg": []byte("{{.Generation}}"), "logo.png": []byte(logo)}, nil))
+	assert.NoError(t, WriteAtomic(dataDir, map[string][]byte{"routing-data.json": []byte(testRoutingData)}, nil))
+
+	inputs, err := (&DirectorySource{TemplatesDirectories: []string{templatesDir}, RoutingDataDirectory: dataDir}).Load()
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"a.cfg": "{{.Generation}}", "logo.png.static": logo}, inputs.Templates)
+
+	rendered, err := Render(inputs, false)
+	assert.NoError(t, err)
+	assert.Equal(t, []byte(logo), rendered.Files["logo.png"])
+
+	_, err = DirectoryTemplateData(map[string]string{"logo.png": logo, "logo.png.static": "other"})
+	assert.Error(t, err)
+}
+
+func TestDirectorySource_Watch(t *testing.T) {
+	templatesDir, dataDir, configDir := t.TempDir(), t.TempDir(), t.TempDir()
+	assert.NoError(t, WriteAtomic(templatesDir, map[string][]byte{"a.cfg": []byte("{{.Generation}}")}, nil))
+	assert.NoError(t, WriteAtomic(dataDir, map[string][]byte{"routing-data.json": []byte(testRoutingData)}, nil))
+
+	sidecar, err := New(Options{ConfigDirectory: configDir})
+	assert.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go (&DirectorySource{TemplatesDirectories: []string{templatesDir}, RoutingDataDirectory: dataDir, Sidecar: sidecar}).Start(ctx)
+
+	assert.Eventually(t, func() bool {
+		content, _ := os.ReadFile(filepath.Join(configDir, "a.cfg"))
+		return string(content) == "3"
+	}, 5*time.Second, 50*time.Millisecond)
+
+	routingData := strings.Replace(testRoutingData, `"Generation": 3`, `"Generation": 4`, 1)
+	assert.NoError(t, WriteAtomic(dataDir, map[string][]byte{"routing-data.json": []byte(routingData)}, nil))
+
+	assert.Eventually(t, func() bool {
+		content, _ := os.ReadFile(filepath.Join(configDir, "a.cfg"))
+		return string(content) == "4"
+	}, 5*time.Second, 50*time.Millisecond)
+}
+
+func TestTemplateData(t *testing.T) {
+	cm := &corev1.ConfigMap{
+		Data:       map[string]string{"kamailio.cfg": "{{.Generation}}"},
+		BinaryData: map[string][]byte{"logo.png": {0x89, 'P', 'N', 'G'}, "ca.der.static": {0x30}},
+	}
+
+	templates, err := TemplateData(cm)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{
+		"kamailio.cfg":    "{{.Generation}}",
+		"logo.png.static": "\x89PNG",
+		"ca.der.static":   "0",
+	}, templates)
+
+	cm.Data["logo.png.static"] = "text"
+	_, err = TemplateData(cm)
+	assert.Error(t, err)
+}
+
+func TestRender_Layering(t *testing.T) {
+	base := map[string]string{
+		"_helpers.tpl":    `{{define "listen"}}listen=udp:0.0.0.0:{{.UDPPort}}{{end}}`,
+		"kamailio.cfg":    `{{template "listen" .}}`,
+		"tls__ca.pem":     "base",
+		"logo.txt.static": "{{not rendered}}",
+	}
+	overrides := map[string]string{
+		"tls__ca.pem": "override",
+	}
+
+	rendered, err := Render(&Inputs{Templates: MergeLayers(base, overrides), RoutingData: testRoutingData}, false)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string][]byte{
+		"kamailio.cfg": []byte("listen=udp:0.0.0.0:5060"),
+		"tls/ca.pem":   []byte("override"),
+		"logo.txt":     []byte("{{not rendered}}"),
+	}, rendered.Files)
+
+	_, err = Render(&Inputs{Templates: map[string]string{"a": "a", "a.static": "b"}, RoutingData: testRoutingData}, false)
+	assert.Error(t, err)
+
+	_, err = Render(&Inputs{Templates: map[string]string{"a__..__b": "a"}, RoutingData: testRoutingData}, false)
+	assert.Error(t, err)
+}
+
+func TestWriteAtomic_Nested(t *testing.T) {
+	dir := t.TempDir()
+
+	err := WriteAtomic(dir, map[string][]byte{"tls/ca.pem": []byte("ca"), "a.cfg": []byte("a")}, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, "ca", readFile(t, filepath.Join(dir, "tls", "ca.pem")))
+
+	err = WriteAtomic(dir, map[string][]byte{"a.cfg": []byte("a")}, nil)
+	assert.NoError(t, err)
+	_, err = os.Lstat(filepath.Join(dir, "tls"))
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestRender_Secrets(t *testing.T) {
+	inputs := &Inputs{
+		Templates: map[string]string{
+			"db.cfg":       `password={{.Secrets.password}}`,
+			"kamailio.cfg": `port={{.UDPPort}}`,
+		},
+		RoutingData: testRoutingData,
+		Secrets:     map[string]string{"password": "s3cret"},
+	}
+
+	rendered, err := Render(inputs, false)
+	assert.NoError(t, err)
+	assert.Equal(t, "password=s3cret", string(rendered.Files["db.cfg"]))
+	assert.Equal(t, map[string]bool{"db.cfg": true}, rendered.Sensitive)
+
+	// the secrets change the hash, but are not contained in the routing-data
+	hash := inputs.Hash()
+	inputs.Secrets["password"] = "changed"
+	assert.NotEqual(t, hash, inputs.Hash())
+
+	dir := t.TempDir()
+	assert.NoError(t, WriteAtomic(dir, rendered.Files, rendered.Sensitive))
+
+	info, err := os.Stat(filepath.Join(dir, "db.cfg"))
+	assert.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+
+	info, err = os.Stat(filepath.Join(dir, "kamailio.cfg"))
+	assert.NoError(t, err)
+	assert.Equal(t, os.FileMode(0644), info.Mode().Perm())
+}
+
+func TestSampleTemplates(t *testing.T) {
+	content, err := os.ReadFile("../config/samples/template-configmap.yaml")
+	assert.NoError(t, err)
+	cm := &corev1.ConfigMap{}
+	assert.NoError(t, yaml.Unmarshal(content, cm))
+
+	templates, err := TemplateData(cm)
+	assert.NoError(t, err)
+	rendered, err := Render(&Inputs{Templates: templates, RoutingData: testRoutingData}, false)
+	assert.NoError(t, err)
+	assert.NotEmpty(t, rendered.Actions)
+
+	// every RPC action must be served by a module loaded by the configuration
+	loaded := map[string]bool{"core": true}
+	for _, match := range regexp.MustCompile(`(?m)^\s*loadmodule\s+"([^"]+)\.so"`).FindAllStringSubmatch(string(rendered.Files["kamailio.cfg"]), -1) {
+		loaded[match[1]] = true
+	}
+
+	for _, action := range rendered.Actions {
+		if action.RPC == "" {
+			continue
+		}
+		module, _, _ := strings.Cut(action.RPC, ".")
+		assert.True(t, loaded[module], "the module %s of the action '%s' is not loaded", module, action.String())
+	}
+}
@@ -0,0 +1,58 @@
+package sidecar
+
+import (
+	"context"
+)
+
+// The reasons of a Result
+const (
+	Reason_Applied          = "Applied"
+	Reason_RenderFailed     = "RenderFailed"
+	Reason_ValidationFailed = "ValidationFailed"
+	Reason_ReloadFailed     = "ReloadFailed"
+)
+
+// Result is the outcome of Sidecar.Apply
+type Result struct {
+	// Hash of the Inputs
+	Hash string
+
+	// RoutingDataHash is the hash of the routing-data, like RouterInstanceStatus.RouterDataHash
+	RoutingDataHash string
+	Generation      int
+
+	// Reason is one of the Reason_* constants
+	Reason string
+
+	// Err is set, if the configuration has not been applied
+	Err error
+
+	// Activated is true, if the rendered files are active in the ConfigDirectory
+	Activated bool
+
+	// RolledBack is true, if the last known-good configuration has been restored
+	RolledBack bool
+
+	// Actions are the executed reload actions, in order
+	Actions []ActionResult
+}
+
+// Reporter is notified about the result of each Apply, which has not been skipped
+type Reporter interface {
+	Report(ctx context.Context, result *Result) error
+}
+
+// Reporters notifies all reporters, and returns the first error
+type Reporters []Reporter
+
+func (reporters Reporters) Report(ctx context.Context, result *Result) error {
+	var firstErr error
+	for _, reporter := range reporters {
+		err := reporter.Report(ctx, result)
+		if err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+
+	return firstErr
+}
@@ -0,0 +1,89 @@
+package sidecar
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// the placeholder in the check command, which is replaced by the staging directory
+const stagingDirPlaceholder = "{dir}"
+
+// the timeout for a single check command
+const checkTimeout = 30 * time.Second
+
+// pythonCheckScript compiles a script without writing bytecode into the staging directory
+const pythonCheckScript = "import sys; compile(open(sys.argv[1]).read(), sys.argv[1], 'exec')"
+
+// ValidationError is returned if the rendered configuration has been rejected by a check
+type ValidationError struct {
+	Command string
+	Output  string
+	Err     error
+}
+
+func (err *ValidationError) Error() string {
+	return fmt.Sprintf("check '%s' failed: %v: %s", err.Command, err.Err, strings.TrimSpace(err.Output))
+}
+
+func (err *ValidationError) Unwrap() error {
+	return err.Err
+}
+
+// validate runs the configured checks against the files staged in dir
+func (sidecar *Sidecar) validate(ctx context.Context, dir string, files map[string][]byte) error {
+	if sidecar.Options.CheckCommand != "" {
+		args := strings.Fields(strings.ReplaceAll(sidecar.Options.CheckCommand, stagingDirPlaceholder, dir))
+		err := runCheck(ctx, dir, args)
+		if err != nil {
+			return err
+		}
+	}
+
+	if sidecar.Options.PythonCheck != "" {
+		names := []string{}
+		for name := range files {
+			if filepath.Ext(name) == ".py" {
+				names = append(names, name)
+			}
+		}
+		sort.Strings(names)
+
+		for _, name := range names {
+			err := runCheck(ctx, dir, []string{sidecar.Options.PythonCheck, "-c", pythonCheckScript, filepath.Join(dir, name)})
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// runCheck runs a check command in dir, and returns a ValidationError if it fails
+func runCheck(ctx context.Context, dir string, args []string) error {
+	if len(args) == 0 {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, checkTimeout)
+	defer cancel()
+
+	var output bytes.Buffer
+	cmd := exec.CommandContext(ctx, args[0], args[1:]...)
+	cmd.Dir = dir
+	cmd.Stdout = &output
+	cmd.Stderr = &output
+
+	err := cmd.Run()
+	if err != nil {
+		return &ValidationError{Command: strings.Join(args, " "), Output: output.String(), Err: err}
+	}
+
+	return nil
+}
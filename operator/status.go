@@ -0,0 +1,253 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/yaml"
+
+	kasicov1 "github.com/world-direct/kasico/operator/api/v1"
+	"github.com/world-direct/kasico/operator/controllers"
+)
+
+type statusOptions struct {
+	allNamespaces bool
+	routerName    string
+	output        string
+}
+
+func newStatusCommand() *cobra.Command {
+	var opts statusOptions
+	statusCmd := &cobra.Command{
+		Use:   "status",
+		Short: "Shows the state of the RouterInstances",
+		Long: `Shows for every RouterInstance the ingress class, the external IPs of the router Service,
+the number of Ingresses and published rules, the published routing-data, the rollout to the
+router pods, and the Ingresses with failing conditions. PODS are the router pods scheduled
+by the DaemonSet, of the desired ones.`,
+		Example: `  kasico status
+  kasico status -A -o wide`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return main_status(cmd.Context(), cmd.OutOrStdout(), opts)
+		},
+	}
+
+	statusCmd.Flags().BoolVarP(&opts.allNamespaces, "all-namespaces", "A", false, "Show the RouterInstances of all namespaces")
+	statusCmd.Flags().StringVar(&opts.routerName, "router", "", "The name of the RouterInstance, all if empty")
+	statusCmd.Flags().StringVarP(&opts.output, "output", "o", "", "The output format: wide, json or yaml")
+
+	return statusCmd
+}
+
+// routerStatus is the state of a RouterInstance
+type routerStatus struct {
+	Namespace        string   `json:"namespace"`
+	Name             string   `json:"name"`
+	IngressClassName string   `json:"ingressClassName"`
+	ExternalIPs      []string `json:"externalIPs"`
+	Ingresses        int      `json:"ingresses"`
+	Rules            int      `json:"rules"`
+	RouterDataHash   string   `json:"routerDataHash,omitempty"`
+	Generation       int      `json:"generation"`
+	CurrentRevision  string   `json:"currentRevision,omitempty"`
+	// DesiredPods and Pods are the router pods which should be, and are scheduled by the DaemonSet
+	DesiredPods int `json:"desiredPods"`
+	Pods        int `json:"pods"`
+
+	ReadyPods   int `json:"readyPods"`
+	UpdatedPods int `json:"updatedPods"`
+
+	// FailingConditions are the conditions of the RouterInstance, which are false
+	FailingConditions []metav1.Condition `json:"failingConditions,omitempty"`
+
+	FailingIngresses []failingIngress `json:"failingIngresses,omitempty"`
+}
+
+type failingIngress struct {
+	Name      string           `json:"name"`
+	Condition metav1.Condition `json:"condition"`
+}
+
+func main_status(ctx context.Context, out io.Writer, opts statusOptions) error {
+	namespace, err := listNamespace(opts.allNamespaces)
+	if err != nil {
+		return err
+	}
+
+	c, err := newClient()
+	if err != nil {
+		return err
+	}
+
+	routers := &kasicov1.RouterInstanceList{}
+	err = c.List(ctx, routers, client.InNamespace(namespace))
+	if err != nil {
+		return err
+	}
+
+	// the Ingresses of a class may be in any namespace
+	ingresses := &kasicov1.IngressList{}
+	err = c.List(ctx, ingresses)
+	if err != nil {
+		return err
+	}
+
+	statuses := []routerStatus{}
+	for i := range routers.Items {
+		router := &routers.Items[i]
+		if opts.routerName != "" && router.Name != opts.routerName {
+			continue
+		}
+
+		status, err := getRouterStatus(ctx, c, router, ingresses.Items)
+		if err != nil {
+			return err
+		}
+		statuses = append(statuses, *status)
+	}
+
+	return printStatus(out, statuses, opts.output)
+}
+
+// printStatus prints the statuses in the output format
+func printStatus(out io.Writer, statuses []routerStatus, output string) error {
+	switch output {
+	case "json":
+		return printJSON(out, statuses)
+	case "yaml":
+		bytes, err := yaml.Marshal(statuses)
+		if err != nil {
+			return err
+		}
+		_, err = out.Write(bytes)
+		return err
+	case "", "wide":
+		printRouterStatuses(out, statuses, output == "wide")
+		return nil
+	}
+
+	return fmt.Errorf("unknown output format %s", output)
+}
+
+func getRouterStatus(ctx context.Context, c client.Reader, router *kasicov1.RouterInstance, ingresses []kasicov1.Ingress) (*routerStatus, error) {
+	status := &routerStatus{
+		Namespace:        router.Namespace,
+		Name:             router.Name,
+		IngressClassName: router.Spec.IngressClassName,
+		ExternalIPs:      []string{},
+		RouterDataHash:   router.Status.RouterDataHash,
+		Generation:       router.Status.RoutingDataGeneration,
+		CurrentRevision:  router.Status.CurrentRevision,
+		ReadyPods:        router.Status.ReadyPods,
+		UpdatedPods:      router.Status.UpdatedPods,
+	}
+
+	// the pods which applied no routing-data yet are only known to the DaemonSet
+	daemonSet := &appsv1.DaemonSet{}
+	err := c.Get(ctx, types.NamespacedName{Namespace: router.Namespace, Name: controllers.Name_Daemonset}, daemonSet)
+	if err != nil && !errors.IsNotFound(err) {
+		return nil, err
+	}
+	status.DesiredPods = int(daemonSet.Status.DesiredNumberScheduled)
+	status.Pods = int(daemonSet.Status.CurrentNumberScheduled)
+
+	for _, condition := range router.Status.Conditions {
+		if condition.Status == metav1.ConditionFalse {
+			status.FailingConditions = append(status.FailingConditions, condition)
+		}
+	}
+
+	service := &corev1.Service{}
+	err = c.Get(ctx, types.NamespacedName{Namespace: router.Namespace, Name: controllers.Name_Service}, service)
+	if err != nil && !errors.IsNotFound(err) {
+		return nil, err
+	}
+	status.ExternalIPs = append(status.ExternalIPs, service.Spec.ExternalIPs...)
+	for _, ingress := range service.Status.LoadBalancer.Ingress {
+		if ingress.IP != "" {
+			status.ExternalIPs = append(status.ExternalIPs, ingress.IP)
+		} else if ingress.Hostname != "" {
+			status.ExternalIPs = append(status.ExternalIPs, ingress.Hostname)
+		}
+	}
+
+	for _, ingress := range ingresses {
+		if ingress.Spec.IngressClassName != router.Spec.IngressClassName {
+			continue
+		}
+
+		status.Ingresses++
+		for _, condition := range ingress.Status.Conditions {
+			if condition.Status == metav1.ConditionFalse {
+				status.FailingIngresses = append(status.FailingIngresses, failingIngress{
+					Name:      ingress.Namespace + "/" + ingress.Name,
+					Condition: condition,
+				})
+			}
+		}
+	}
+
+	sort.Slice(status.FailingIngresses, func(i, j int) bool {
+		return status.FailingIngresses[i].Name < status.FailingIngresses[j].Name
+	})
+
+	routingData, err := controllers.PublishedRoutingData(ctx, c, router)
+	if err != nil {
+		return nil, err
+	}
+	status.Rules = len(routingData.Rules)
+
+	return status, nil
+}
+
+func printRouterStatuses(out io.Writer, statuses []routerStatus, wide bool) {
+	w := tabwriter.NewWriter(out, 0, 4, 3, ' ', 0)
+
+	header := "NAMESPACE\tNAME\tCLASS\tEXTERNAL-IP\tINGRESSES\tRULES\tGENERATION\tUPDATED\tREADY\tPODS\tFAILING"
+	if wide {
+		header += "\tHASH\tREVISION"
+	}
+	fmt.Fprintln(w, header)
+
+	for _, status := range statuses {
+		externalIPs := strings.Join(status.ExternalIPs, ",")
+		if externalIPs == "" {
+			externalIPs = "<pending>"
+		}
+
+		line := fmt.Sprintf("%s\t%s\t%s\t%s\t%d\t%d\t%d\t%d\t%d\t%d/%d\t%d",
+			status.Namespace, status.Name, status.IngressClassName, externalIPs, status.Ingresses, status.Rules,
+			status.Generation, status.UpdatedPods, status.ReadyPods, status.Pods, status.DesiredPods, len(status.FailingIngresses))
+		if wide {
+			line += fmt.Sprintf("\t%s\t%s", status.RouterDataHash, status.CurrentRevision)
+		}
+		fmt.Fprintln(w, line)
+	}
+	w.Flush()
+
+	for _, status := range statuses {
+		if len(status.FailingConditions) == 0 && len(status.FailingIngresses) == 0 {
+			continue
+		}
+
+		fmt.Fprintf(out, "\nRouterInstance %s/%s:\n", status.Namespace, status.Name)
+		for _, condition := range status.FailingConditions {
+			fmt.Fprintf(out, "  %s: %s %s\n", condition.Type, condition.Reason, condition.Message)
+		}
+		for _, failing := range status.FailingIngresses {
+			fmt.Fprintf(out, "  Ingress %s %s: %s %s\n", failing.Name, failing.Condition.Type, failing.Condition.Reason, failing.Condition.Message)
+		}
+	}
+}
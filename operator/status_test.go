@@ -0,0 +1,138 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/yaml"
+
+	kasicov1 "github.com/world-direct/kasico/operator/api/v1"
+	"github.com/world-direct/kasico/operator/controllers"
+)
+
+func testRouterStatus() routerStatus {
+	return routerStatus{
+		Namespace:        "kasico",
+		Name:             "router",
+		IngressClassName: "default",
+		ExternalIPs:      []string{"192.0.2.1"},
+		Ingresses:        2,
+		Rules:            1,
+		RouterDataHash:   "md5:abc",
+		Generation:       3,
+		CurrentRevision:  "routing-data-router-abc",
+		DesiredPods:      3,
+		Pods:             2,
+		ReadyPods:        2,
+		UpdatedPods:      1,
+	}
+}
+
+func TestGetRouterStatus(t *testing.T) {
+	router := &kasicov1.RouterInstance{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "kasico", Name: "router"},
+		Spec:       kasicov1.RouterInstanceSpec{IngressClassName: "default"},
+		Status: kasicov1.RouterInstanceStatus{
+			RoutingDataGeneration: 3,
+			ReadyPods:             2,
+			UpdatedPods:           1,
+			// the pods which applied nothing yet are missing here
+			AppliedRoutingData: []kasicov1.AppliedRoutingData{{Pods: 1}},
+		},
+	}
+
+	daemonSet := &appsv1.DaemonSet{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "kasico", Name: controllers.Name_Daemonset},
+		Status:     appsv1.DaemonSetStatus{DesiredNumberScheduled: 3, CurrentNumberScheduled: 2},
+	}
+
+	service := &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "kasico", Name: controllers.Name_Service},
+		Status: corev1.ServiceStatus{LoadBalancer: corev1.LoadBalancerStatus{Ingress: []corev1.LoadBalancerIngress{
+			{IP: "192.0.2.1"}, {Hostname: "sip.example.com"},
+		}}},
+	}
+
+	routingData, err := controllers.MarshalRoutingData(&controllers.RoutingData{Rules: []controllers.RoutingRule{{Headnumber: "+43512"}}})
+	assert.NoError(t, err)
+	cm := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "kasico", Name: controllers.Name_ConfigMap},
+		Data:       map[string]string{controllers.Name_RouningDataJson: routingData},
+	}
+
+	failing := metav1.Condition{Type: "Published", Status: metav1.ConditionFalse, Reason: "Shadowed"}
+	ingresses := []kasicov1.Ingress{
+		{ObjectMeta: metav1.ObjectMeta{Namespace: "b", Name: "tenant"}, Spec: kasicov1.IngressSpec{IngressClassName: "default"},
+			Status: kasicov1.IngressStatus{Conditions: []metav1.Condition{failing}}},
+		{ObjectMeta: metav1.ObjectMeta{Namespace: "a", Name: "tenant"}, Spec: kasicov1.IngressSpec{IngressClassName: "default"}},
+		{ObjectMeta: metav1.ObjectMeta{Namespace: "a", Name: "other"}, Spec: kasicov1.IngressSpec{IngressClassName: "other"}},
+	}
+
+	c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(daemonSet, service, cm).Build()
+	status, err := getRouterStatus(context.Background(), c, router, ingresses)
+	assert.NoError(t, err)
+
+	assert.Equal(t, 3, status.DesiredPods)
+	assert.Equal(t, 2, status.Pods)
+	assert.Equal(t, 2, status.ReadyPods)
+	assert.Equal(t, 1, status.UpdatedPods)
+	assert.Equal(t, []string{"192.0.2.1", "sip.example.com"}, status.ExternalIPs)
+	assert.Equal(t, 2, status.Ingresses)
+	assert.Equal(t, 1, status.Rules)
+	assert.Equal(t, []failingIngress{{Name: "b/tenant", Condition: failing}}, status.FailingIngresses)
+
+	// without DaemonSet, Service and routing-data the router is not deployed yet
+	c = fake.NewClientBuilder().WithScheme(scheme).Build()
+	status, err = getRouterStatus(context.Background(), c, router, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, status.Pods)
+	assert.Equal(t, []string{}, status.ExternalIPs)
+	assert.Equal(t, 0, status.Rules)
+}
+
+func TestPrintStatus(t *testing.T) {
+	statuses := []routerStatus{testRouterStatus()}
+
+	out := &bytes.Buffer{}
+	assert.NoError(t, printStatus(out, statuses, ""))
+	assert.Equal(t, "NAMESPACE   NAME     CLASS     EXTERNAL-IP   INGRESSES   RULES   GENERATION   UPDATED   READY   PODS   FAILING\n"+
+		"kasico      router   default   192.0.2.1     2           1       3            1         2       2/3    0\n", out.String())
+
+	out.Reset()
+	assert.NoError(t, printStatus(out, statuses, "wide"))
+	assert.Contains(t, out.String(), "HASH      REVISION\n")
+	assert.Contains(t, out.String(), "md5:abc   routing-data-router-abc\n")
+
+	for _, format := range []string{"json", "yaml"} {
+		out.Reset()
+		assert.NoError(t, printStatus(out, statuses, format))
+
+		parsed := []routerStatus{}
+		if format == "json" {
+			assert.NoError(t, json.Unmarshal(out.Bytes(), &parsed))
+		} else {
+			assert.NoError(t, yaml.Unmarshal(out.Bytes(), &parsed))
+		}
+		assert.Equal(t, statuses, parsed, format)
+	}
+
+	assert.Error(t, printStatus(out, statuses, "csv"))
+}
+
+func TestPrintStatus_Failing(t *testing.T) {
+	status := testRouterStatus()
+	status.ExternalIPs = []string{}
+	status.FailingIngresses = []failingIngress{{Name: "a/tenant", Condition: metav1.Condition{Type: "Published", Reason: "Shadowed", Message: "the rule is shadowed"}}}
+
+	out := &bytes.Buffer{}
+	assert.NoError(t, printStatus(out, []routerStatus{status}, ""))
+	assert.Contains(t, out.String(), "<pending>")
+	assert.Contains(t, out.String(), "\nRouterInstance kasico/router:\n  Ingress a/tenant Published: Shadowed the rule is shadowed\n")
+}